@@ -0,0 +1,125 @@
+// Copyright 2026 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"k8s-ne-device-plugin/pkg/allocator"
+	"k8s-ne-device-plugin/pkg/config"
+	"k8s-ne-device-plugin/pkg/doctor"
+	"k8s-ne-device-plugin/pkg/nitro_enclaves_cpu_plugin"
+	"k8s-ne-device-plugin/pkg/nitro_enclaves_device_monitor"
+	"k8s-ne-device-plugin/pkg/nitro_enclaves_device_plugin"
+	"k8s-ne-device-plugin/pkg/nitro_enclaves_memory_plugin"
+	"os"
+	"path/filepath"
+
+	"github.com/golang/glog"
+)
+
+// pluginSocketPattern matches the sockets of all plugins of this project.
+const pluginSocketPattern = "nitro_enclaves*.sock"
+
+// diagnoseConfig reports configuration errors. Unless the config is strict, the plugin
+// starts with the defaults of the invalid values.
+func diagnoseConfig(pluginConfig *config.PluginConfig, err error) []doctor.Result {
+	switch {
+	case err != nil && pluginConfig.Strict:
+		return []doctor.Result{doctor.Failed("config", "%v", err)}
+	case err != nil:
+		return []doctor.Result{doctor.Warned("config", "invalid values fall back to their defaults: %v", err)}
+	default:
+		return []doctor.Result{doctor.Passed("config", "valid")}
+	}
+}
+
+// diagnoseAllocatorConfig checks the allocator config the plugins cross-check their pools with.
+func diagnoseAllocatorConfig(path string) []doctor.Result {
+	allocatorConfig, err := allocator.LoadConfig(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		return []doctor.Result{doctor.Warned("allocator config", "%s does not exist, the enclave pools can't be cross-checked", path)}
+	case err != nil:
+		return []doctor.Result{doctor.Failed("allocator config", "%v", err)}
+	case allocatorConfig.CPUPool != "":
+		return []doctor.Result{doctor.Passed("allocator config", "%s requests %d MiB and CPUs %v", path, allocatorConfig.MemoryMiB, allocatorConfig.CPUs())}
+	default:
+		return []doctor.Result{doctor.Passed("allocator config", "%s requests %d MiB and %d CPUs", path, allocatorConfig.MemoryMiB, allocatorConfig.CPUCount)}
+	}
+}
+
+// diagnoseSockets checks that the kubelet serves its registration socket and that no socket
+// of a plugin is left behind without being served.
+func diagnoseSockets(pluginConfig *config.PluginConfig) []doctor.Result {
+	results := []doctor.Result{}
+
+	kubeletSocket := pluginConfig.KubeletSocketPath()
+	if err := nitro_enclaves_device_monitor.CheckSocket(kubeletSocket); err != nil {
+		results = append(results, doctor.Failed("kubelet socket", "%s is not served: %v", kubeletSocket, err))
+	} else {
+		results = append(results, doctor.Passed("kubelet socket", "%s is served", kubeletSocket))
+	}
+
+	sockets, _ := filepath.Glob(filepath.Join(pluginConfig.DevicePluginPath(), pluginSocketPattern))
+	stale := []string{}
+	for _, socket := range sockets {
+		if err := nitro_enclaves_device_monitor.CheckSocket(socket); err != nil {
+			stale = append(stale, socket)
+		}
+	}
+	switch {
+	case len(stale) > 0:
+		results = append(results, doctor.Warned("plugin sockets", "%v are stale, i.e. not served by any plugin", stale))
+	case len(sockets) > 0:
+		results = append(results, doctor.Passed("plugin sockets", "%v are served", sockets))
+	default:
+		results = append(results, doctor.Passed("plugin sockets", "no plugin sockets in %s", pluginConfig.DevicePluginPath()))
+	}
+
+	return results
+}
+
+// runDoctor runs the preflight checks of the node with the discovery code of the plugins,
+// prints the report to stdout and returns the exit code: 1 if a check failed, 2 on usage errors.
+func runDoctor(args []string, stdout io.Writer) int {
+	fs := subcommandFlagSet("doctor")
+	output := fs.String("output", "text", "Report format: text or json")
+	configLoader := config.NewLoader()
+	configLoader.RegisterFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *output != "text" && *output != "json" {
+		fmt.Fprintf(fs.Output(), "unknown output format %q\n", *output)
+		return 2
+	}
+
+	pluginConfig, err := configLoader.Inspect()
+	report := doctor.NewReport(
+		diagnoseConfig(pluginConfig, err),
+		nitro_enclaves_device_plugin.Diagnose(pluginConfig),
+		nitro_enclaves_cpu_plugin.Diagnose(pluginConfig),
+		nitro_enclaves_memory_plugin.Diagnose(pluginConfig),
+		diagnoseAllocatorConfig(pluginConfig.AllocatorConfigPath),
+		diagnoseSockets(pluginConfig),
+	)
+	glog.Flush()
+
+	if *output == "json" {
+		err = report.WriteJSON(stdout)
+	} else {
+		err = report.WriteText(stdout)
+	}
+	if err != nil {
+		glog.Errorf("Error while writing the report: %v", err)
+		return 1
+	}
+
+	if report.Failed() {
+		return 1
+	}
+	return 0
+}
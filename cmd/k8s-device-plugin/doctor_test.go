@@ -0,0 +1,109 @@
+// Copyright 2026 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"k8s-ne-device-plugin/pkg/config"
+	"k8s-ne-device-plugin/pkg/cpulist"
+	"k8s-ne-device-plugin/pkg/doctor"
+	"k8s-ne-device-plugin/pkg/fake_kubelet"
+	"k8s-ne-device-plugin/pkg/simulator"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// doctorArgs points the doctor to the simulated host and the fake kubelet.
+func doctorArgs(host *simulator.Host, kubelet *fake_kubelet.Kubelet, args ...string) []string {
+	c := &config.PluginConfig{}
+	host.Apply(c)
+	return append([]string{
+		"-dev-root", c.DevRoot,
+		"-sys-root", c.SysRoot,
+		"-allocator-config-path", c.AllocatorConfigPath,
+		"-device-plugin-dir", kubelet.Dir(),
+	}, args...)
+}
+
+// resultsByCheck runs the doctor and maps its JSON report to the status of each check.
+func resultsByCheck(t *testing.T, args []string, wantCode int) map[string]doctor.Status {
+	t.Helper()
+	var out bytes.Buffer
+	if code := runDoctor(append(args, "-output", "json"), &out); code != wantCode {
+		t.Fatalf("runDoctor() = %v, want %v:\n%s", code, wantCode, out.String())
+	}
+	report := doctor.Report{}
+	if err := json.Unmarshal(out.Bytes(), &report); err != nil {
+		t.Fatalf("Invalid JSON report: %v\n%s", err, out.String())
+	}
+	statuses := map[string]doctor.Status{}
+	for _, result := range report.Results {
+		statuses[result.Check] = result.Status
+	}
+	return statuses
+}
+
+func TestDoctor(t *testing.T) {
+	host, err := simulator.NewHost(t.TempDir(), simulator.DefaultProfile())
+	if err != nil {
+		t.Fatal(err)
+	}
+	kubelet := fake_kubelet.New(t.TempDir())
+	if err = kubelet.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer kubelet.Stop()
+
+	// the simulated device is a regular file
+	statuses := resultsByCheck(t, doctorArgs(host, kubelet), 0)
+	for check, status := range statuses {
+		want := doctor.Pass
+		if check == "device" {
+			want = doctor.Warn
+		}
+		if status != want {
+			t.Errorf("%s check = %v, want %v", check, status, want)
+		}
+	}
+
+	var out bytes.Buffer
+	if code := runDoctor(doctorArgs(host, kubelet), &out); code != 0 || !strings.Contains(out.String(), "[PASS] kubelet socket") {
+		t.Errorf("runDoctor() = %v, want a text report:\n%s", code, out.String())
+	}
+
+	// a stale plugin socket, a missing device and online enclave CPUs
+	if err = os.WriteFile(filepath.Join(kubelet.Dir(), "nitro_enclaves.sock"), nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err = host.SetDevicePresent(false); err != nil {
+		t.Fatal(err)
+	}
+	enclaveCPUs, _ := cpulist.Parse(host.Status().EnclaveCPUs)
+	if err = host.SetCPUsOnline(enclaveCPUs, true); err != nil {
+		t.Fatal(err)
+	}
+	kubelet.Stop()
+
+	statuses = resultsByCheck(t, doctorArgs(host, kubelet), 1)
+	want := map[string]doctor.Status{
+		"plugin sockets":   doctor.Warn,
+		"device":           doctor.Fail,
+		"offline CPUs":     doctor.Fail,
+		"enclave CPU pool": doctor.Fail,
+		"kubelet socket":   doctor.Fail,
+		"hugepages":        doctor.Pass,
+	}
+	for check, status := range want {
+		if statuses[check] != status {
+			t.Errorf("%s check = %v, want %v", check, statuses[check], status)
+		}
+	}
+
+	if code := runDoctor([]string{"-output", "yaml"}, &out); code != 2 {
+		t.Errorf("runDoctor() = %v with an unknown output format, want 2", code)
+	}
+}
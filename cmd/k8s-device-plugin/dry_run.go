@@ -0,0 +1,66 @@
+// Copyright 2026 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"k8s-ne-device-plugin/pkg/config"
+	"k8s-ne-device-plugin/pkg/introspection"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/golang/glog"
+)
+
+// healthChecker is a device plugin which checks the health of its devices only while it runs,
+// unless asked to.
+type healthChecker interface {
+	CheckHealth()
+}
+
+// runDryRun discovers the devices of all plugins enabled by pluginConfig and prints them with
+// their health and topology, without serving the plugins or contacting the kubelet. configErr
+// holds the errors found while loading pluginConfig. It returns the exit code: 1 on config
+// errors.
+func runDryRun(pluginConfig *config.PluginConfig, configErr error, stdout io.Writer) int {
+	if configErr != nil {
+		glog.Errorf("Invalid plugin config: %v", configErr)
+	}
+
+	tw := tabwriter.NewWriter(stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "RESOURCE\tDEVICE\tHEALTH\tNUMA\n")
+	for _, plugin := range newPlugins(pluginConfig) {
+		if checker, ok := plugin.(healthChecker); ok {
+			checker.CheckHealth()
+		}
+
+		devices := introspection.Devices(plugin.Devices())
+		if len(devices) == 0 {
+			fmt.Fprintf(tw, "%s\t-\t-\t-\n", plugin.ResourceName())
+		}
+		for _, device := range devices {
+			nodes := make([]string, 0, len(device.NUMANodes))
+			for _, node := range device.NUMANodes {
+				nodes = append(nodes, strconv.FormatInt(node, 10))
+			}
+			numa := strings.Join(nodes, ",")
+			if numa == "" {
+				numa = "-"
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", plugin.ResourceName(), device.ID, device.Health, numa)
+		}
+	}
+	if err := tw.Flush(); err != nil {
+		glog.Errorf("Error while writing the devices: %v", err)
+		return 1
+	}
+	glog.Flush()
+
+	if configErr != nil {
+		return 1
+	}
+	return 0
+}
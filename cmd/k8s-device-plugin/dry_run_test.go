@@ -0,0 +1,64 @@
+// Copyright 2026 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"k8s-ne-device-plugin/pkg/config"
+	"k8s-ne-device-plugin/pkg/simulator"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestDryRun(t *testing.T) {
+	host, err := simulator.NewHost(t.TempDir(), simulator.DefaultProfile())
+	if err != nil {
+		t.Fatal(err)
+	}
+	pluginConfig := config.Defaults()
+	pluginConfig.MaxEnclavesPerNode = 2
+	pluginConfig.EnclaveCPUAdvertisement = true
+	pluginConfig.EnclaveMemoryAdvertisement = true
+	pluginConfig.DevicePluginDir = t.TempDir()
+	host.Apply(pluginConfig)
+
+	var out bytes.Buffer
+	if code := runDryRun(pluginConfig, nil, &out); code != 0 {
+		t.Fatalf("runDryRun() = %v, want 0:\n%s", code, out.String())
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	counts := map[string]int{}
+	for _, line := range lines[1:] {
+		counts[strings.Fields(line)[0]]++
+	}
+	// 4 enclave CPUs and 1024 MiB of hugepages in blocks of 256 MiB
+	want := map[string]int{"aws.ec2.nitro/nitro_enclaves": 2, "aws.ec2.nitro/nitro_enclaves_cpus": 4, "aws.ec2.nitro/nitro_enclaves_memory": 4}
+	for resource, count := range want {
+		if counts[resource] != count {
+			t.Errorf("runDryRun() printed %d %s devices, want %d:\n%s", counts[resource], resource, count, out.String())
+		}
+	}
+	// the simulated device is a regular file, which can be opened
+	if !strings.Contains(out.String(), "Healthy") || strings.Contains(out.String(), "Unhealthy") {
+		t.Errorf("runDryRun() = \n%s\nwant healthy devices only", out.String())
+	}
+
+	if entries, _ := os.ReadDir(pluginConfig.DevicePluginDir); len(entries) != 0 {
+		t.Errorf("runDryRun() created %v in the device plugin directory", entries)
+	}
+
+	// a missing device is reported unhealthy, config errors fail the dry run
+	if err = host.SetDevicePresent(false); err != nil {
+		t.Fatal(err)
+	}
+	out.Reset()
+	if code := runDryRun(pluginConfig, errors.New("max devices per node must be greater than 0"), &out); code != 1 {
+		t.Errorf("runDryRun() = %v with config errors, want 1", code)
+	}
+	if !strings.Contains(out.String(), "Unhealthy") {
+		t.Errorf("runDryRun() = \n%s\nwant unhealthy enclave devices", out.String())
+	}
+}
@@ -4,14 +4,24 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"github.com/golang/glog"
+	"io"
 	"k8s-ne-device-plugin/pkg/config"
+	"k8s-ne-device-plugin/pkg/health"
+	"k8s-ne-device-plugin/pkg/introspection"
+	"k8s-ne-device-plugin/pkg/metrics"
 	"k8s-ne-device-plugin/pkg/nitro_enclaves_cpu_plugin"
 	"k8s-ne-device-plugin/pkg/nitro_enclaves_device_monitor"
 	"k8s-ne-device-plugin/pkg/nitro_enclaves_device_plugin"
+	"k8s-ne-device-plugin/pkg/nitro_enclaves_memory_plugin"
+	"k8s-ne-device-plugin/pkg/simulator"
 	"os"
+	"time"
+
+	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
 )
 
 // These variables are populated at build time via -ldflags -X.
@@ -21,8 +31,140 @@ var (
 	buildDate = "unknown"
 )
 
+// devicePlugin is a device plugin which can apply a reloaded config at runtime.
+type devicePlugin interface {
+	nitro_enclaves_device_monitor.IBasicDevicePlugin
+	Devices() []*pluginapi.Device
+	Reconfigure(config *config.PluginConfig)
+}
+
+// newPlugins creates all device plugins enabled by pluginConfig, in the order they are started.
+func newPlugins(pluginConfig *config.PluginConfig) []devicePlugin {
+	// create nitro enclave device plugin
+	plugins := []devicePlugin{nitro_enclaves_device_plugin.NewNitroEnclavesDevicePlugin(pluginConfig)}
+
+	// create nitro enclave cpu device plugin to advertise available cpus
+	if pluginConfig.EnclaveCPUAdvertisement {
+		plugins = append(plugins, nitro_enclaves_cpu_plugin.NewNitroEnclavesCPUDevicePlugin(pluginConfig))
+	}
+
+	// create nitro enclave memory device plugin to advertise the enclave hugepage pool
+	if pluginConfig.EnclaveMemoryAdvertisement {
+		plugins = append(plugins, nitro_enclaves_memory_plugin.NewNitroEnclavesMemoryDevicePlugin(pluginConfig))
+	}
+
+	return plugins
+}
+
+// keepRestartOnlySettings returns a copy of the reloaded config with the settings which
+// require a restart reset to the running ones, warning about each ignored change.
+func keepRestartOnlySettings(running, reloaded *config.PluginConfig) *config.PluginConfig {
+	applied := *reloaded
+	if applied.EnclaveCPUAdvertisement != running.EnclaveCPUAdvertisement ||
+		applied.EnclaveMemoryAdvertisement != running.EnclaveMemoryAdvertisement {
+		glog.Warning("Enabling or disabling device plugins requires a restart, ignoring the change")
+		applied.EnclaveCPUAdvertisement = running.EnclaveCPUAdvertisement
+		applied.EnclaveMemoryAdvertisement = running.EnclaveMemoryAdvertisement
+	}
+	if applied.DevicePluginPath() != running.DevicePluginPath() || applied.DevRoot != running.DevRoot ||
+		applied.HostDevRoot != running.HostDevRoot || applied.SysRoot != running.SysRoot {
+		glog.Warning("Changing the kubelet, device plugin, /dev or /sys paths requires a restart, ignoring the change")
+	}
+	applied.KubeletRootDir = running.KubeletRootDir
+	applied.DevicePluginDir = running.DevicePluginDir
+	applied.DevRoot = running.DevRoot
+	applied.HostDevRoot = running.HostDevRoot
+	applied.SysRoot = running.SysRoot
+	if applied.EnclaveCIDBase != running.EnclaveCIDBase || applied.EnclaveCIDsPerSlot != running.EnclaveCIDsPerSlot {
+		glog.Warning("Changing the enclave CIDs requires a restart, ignoring the change")
+		applied.EnclaveCIDBase = running.EnclaveCIDBase
+		applied.EnclaveCIDsPerSlot = running.EnclaveCIDsPerSlot
+	}
+	if applied.MetricsAddress != running.MetricsAddress || applied.ProbeAddress != running.ProbeAddress ||
+		applied.StatusAddress != running.StatusAddress {
+		glog.Warning("Changing the metrics, probe or status address requires a restart, ignoring the change")
+		applied.MetricsAddress = running.MetricsAddress
+		applied.ProbeAddress = running.ProbeAddress
+		applied.StatusAddress = running.StatusAddress
+	}
+	if applied.MonitorMode != running.MonitorMode || applied.MonitorPollIntervalSeconds != running.MonitorPollIntervalSeconds {
+		glog.Warning("Changing the monitor mode or poll interval requires a restart, ignoring the change")
+		applied.MonitorMode = running.MonitorMode
+		applied.MonitorPollIntervalSeconds = running.MonitorPollIntervalSeconds
+	}
+	return &applied
+}
+
+// applyStartLimits applies the start failure threshold and backoff of pluginConfig to all
+// plugin monitors of the supervisor.
+func applyStartLimits(supervisor *nitro_enclaves_device_monitor.NitroEnclavesSupervisor, pluginConfig *config.PluginConfig) {
+	for _, monitor := range supervisor.Monitors() {
+		monitor.SetStartLimits(pluginConfig.StartFailureThreshold, time.Duration(pluginConfig.StartBackoffMaxSeconds)*time.Second)
+	}
+}
+
+// startSimulation builds the simulated host of the -simulate mode and serves its control endpoint.
+func startSimulation(profilePath, root, controlAddress string) (*simulator.Host, error) {
+	profile := simulator.DefaultProfile()
+	if profilePath != "" {
+		var err error
+		if profile, err = simulator.LoadProfile(profilePath); err != nil {
+			return nil, err
+		}
+	}
+	if root == "" {
+		var err error
+		if root, err = os.MkdirTemp("", "k8s-ne-simulated-host-"); err != nil {
+			return nil, fmt.Errorf("error while creating the simulated host root: %w", err)
+		}
+	}
+
+	host, err := simulator.NewHost(root, profile)
+	if err != nil {
+		return nil, err
+	}
+	glog.Warningf("Simulating a Nitro Enclaves host in %s: %+v", root, host.Status())
+
+	if controlAddress != "" {
+		if _, err = simulator.ServeControl(controlAddress, host); err != nil {
+			return nil, fmt.Errorf("error while starting the simulation control endpoint: %w", err)
+		}
+	}
+	return host, nil
+}
+
+// subcommands run instead of the plugins when named as first argument. They return the
+// exit code of the process.
+var subcommands = map[string]func(args []string, stdout io.Writer) int{
+	"doctor": runDoctor,
+	"status": runStatus,
+}
+
+// subcommandFlagSet returns the flag set of a subcommand, which accepts the logging flags.
+func subcommandFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	flag.CommandLine.VisitAll(func(f *flag.Flag) {
+		fs.Var(f.Value, f.Name, f.Usage)
+	})
+	return fs
+}
+
 func main() {
+	// subcommands come before any flag
+	if len(os.Args) > 1 {
+		if subcommand, ok := subcommands[os.Args[1]]; ok {
+			os.Exit(subcommand(os.Args[2:], os.Stdout))
+		}
+	}
+
 	showVersion := flag.Bool("version", false, "Print version and exit")
+	simulate := flag.Bool("simulate", false, "Run against a simulated Nitro Enclaves host instead of the real /dev and /sys")
+	simulateProfile := flag.String("simulate-profile", "", "YAML or JSON profile of the simulated host (default: 8 CPUs, 4 of them for enclaves, 1 GiB hugepages)")
+	simulateRoot := flag.String("simulate-root", "", "Directory the simulated host is created in (default: a new temporary directory)")
+	simulateControlAddress := flag.String("simulate-control-address", simulator.DefaultControlAddress, "Address of the simulation control endpoint, empty to disable")
+	dryRun := flag.Bool("dry-run", false, "Print the devices the plugins would advertise and exit, without serving or registering them")
+	configLoader := config.NewLoader()
+	configLoader.RegisterFlags(flag.CommandLine)
 	flag.Parse()
 
 	if *showVersion {
@@ -32,29 +174,96 @@ func main() {
 
 	glog.V(0).Infof("Starting K8s Nitro Enclaves device plugin %s (built: %s)", version, buildDate)
 
-	// load config from manifest file and validate
-	pluginConfig := config.LoadConfig()
-
-	// create nitro enclave device, pass it to monitor and start in background
-	enclaveDevicePlugin := nitro_enclaves_device_plugin.NewNitroEnclavesDevicePlugin(pluginConfig)
-	enclaveDeviceMonitor := nitro_enclaves_device_monitor.NewNitroEnclavesMonitor(enclaveDevicePlugin)
-	if enclaveDeviceMonitor == nil {
-		glog.Error("Error while initializing Nitro Enclave Device plugin monitor!")
+	// load config from flags, environment, the optional config file and validate, a dry run
+	// reports every config error instead of only the strict ones
+	var pluginConfig *config.PluginConfig
+	var configErr, err error
+	if *dryRun {
+		pluginConfig, configErr = configLoader.Inspect()
+		// a dry run serves nothing
+		*simulateControlAddress = ""
+	} else if pluginConfig, err = configLoader.Load(); err != nil {
+		glog.Errorf("Invalid plugin config: %v", err)
 		os.Exit(1)
 	}
 
-	// create and start nitro enclave cpu device in background to advertise available cpus
-	if pluginConfig.EnclaveCPUAdvertisement {
-		cpuDevicePlugin := nitro_enclaves_cpu_plugin.NewNitroEnclavesCPUDevicePlugin(pluginConfig)
-		cpuDeviceMonitor := nitro_enclaves_device_monitor.NewNitroEnclavesMonitor(cpuDevicePlugin)
-		if cpuDeviceMonitor == nil {
-			glog.Error("Error while initializing Nitro Enclave CPU Device plugin monitor!")
+	// point the /dev, /sys and allocator config paths to the simulated host
+	var simulatedHost *simulator.Host
+	if *simulate {
+		if simulatedHost, err = startSimulation(*simulateProfile, *simulateRoot, *simulateControlAddress); err != nil {
+			glog.Errorf("Error while starting the simulation: %v", err)
+			os.Exit(1)
+		}
+		simulatedHost.Apply(pluginConfig)
+	}
+
+	if *dryRun {
+		os.Exit(runDryRun(pluginConfig, configErr, os.Stdout))
+	}
+
+	metrics.SetBuildInfo(version, buildDate)
+	if pluginConfig.MetricsAddress != "" {
+		if _, err = metrics.Serve(pluginConfig.MetricsAddress); err != nil {
+			glog.Errorf("Error while starting the metrics endpoint: %v", err)
+			os.Exit(1)
+		}
+	}
+
+	// a single supervisor runs all enabled plugins, restarts them on kubelet restarts and stops them on termination
+	supervisor := nitro_enclaves_device_monitor.NewNitroEnclavesSupervisor(pluginConfig.DevicePluginPath())
+	supervisor.Mode = config.WatchMode(pluginConfig.MonitorMode)
+	supervisor.PollInterval = time.Duration(pluginConfig.MonitorPollIntervalSeconds) * time.Second
+	plugins := newPlugins(pluginConfig)
+	for _, plugin := range plugins {
+		supervisor.Add(plugin)
+	}
+
+	applyStartLimits(supervisor, pluginConfig)
+	monitors := []health.Checker{}
+	for _, monitor := range supervisor.Monitors() {
+		monitors = append(monitors, monitor)
+	}
+
+	// expose liveness and readiness of all enabled plugins
+	if pluginConfig.ProbeAddress != "" {
+		if _, err = health.Serve(pluginConfig.ProbeAddress, monitors...); err != nil {
+			glog.Errorf("Error while starting the health probe endpoint: %v", err)
+			os.Exit(1)
+		}
+	}
+
+	// expose the devices, registration and allocations of all enabled plugins to the status subcommand
+	if pluginConfig.StatusAddress != "" {
+		reporters := []introspection.Reporter{}
+		for _, monitor := range supervisor.Monitors() {
+			reporters = append(reporters, monitor)
+		}
+		if _, err = introspection.Serve(pluginConfig.StatusAddress, reporters...); err != nil {
+			glog.Errorf("Error while starting the status endpoint: %v", err)
 			os.Exit(1)
 		}
-		go cpuDeviceMonitor.Run()
 	}
 
-	// start nitro enclave device plugin and start monitoring loop, main thread is active as long as enclave device
-	// plugin is running and healthy, otherwise terminate and have k8s restart container
-	enclaveDeviceMonitor.Run()
+	// apply config file changes and SIGHUP reloads to the running plugins
+	stopConfigWatch := make(chan interface{})
+	go configLoader.Watch(stopConfigWatch, func(newConfig *config.PluginConfig) {
+		if simulatedHost != nil {
+			simulatedHost.Apply(newConfig)
+		}
+		reloaded := keepRestartOnlySettings(pluginConfig, newConfig)
+		applyStartLimits(supervisor, reloaded)
+		for _, plugin := range plugins {
+			plugin.Reconfigure(reloaded)
+		}
+	})
+
+	// run all plugins, main thread is active until a termination signal is received and all plugins are stopped,
+	// or the supervisor fails, in which case k8s restarts the container
+	err = supervisor.Run(context.Background())
+	close(stopConfigWatch)
+	if err != nil {
+		glog.Errorf("Error while running the plugin supervisor: %v", err)
+		os.Exit(1)
+	}
+	glog.Flush()
 }
@@ -4,8 +4,10 @@
 package main
 
 import (
+	"k8s-ne-device-plugin/pkg/config"
 	"os/exec"
 	"path/filepath"
+	"reflect"
 	"regexp"
 	"strings"
 	"testing"
@@ -65,3 +67,38 @@ func TestVersionFlag(t *testing.T) {
 		})
 	}
 }
+
+// A reload applies the settings the plugins can change at runtime and keeps the others.
+func TestKeepRestartOnlySettings(t *testing.T) {
+	running := config.Defaults()
+	running.EnclaveCIDBase = 16
+
+	reloaded := config.Defaults()
+	reloaded.EnclaveCPUAdvertisement = true
+	reloaded.DevRoot = "/host/dev"
+	reloaded.KubeletRootDir = "/data/kubelet"
+	reloaded.EnclaveCIDBase = 32
+	reloaded.EnclaveCIDsPerSlot = 4
+	reloaded.EnclaveMemoryBlockSizeMiB = 512
+	reloaded.EnclaveExtraMounts = []config.Mount{{HostPath: "/var/log/nitro_enclaves"}}
+	reloaded.MetricsAddress = ":9102"
+	reloaded.StatusAddress = ""
+	reloaded.MonitorMode = string(config.WatchModePoll)
+	reloaded.MonitorPollIntervalSeconds = 1
+	reloaded.StartFailureThreshold = 3
+	reloaded.StartBackoffMaxSeconds = 5
+	reloaded.Strict = true
+
+	want := *running
+	want.EnclaveMemoryBlockSizeMiB = 512
+	want.EnclaveExtraMounts = reloaded.EnclaveExtraMounts
+	want.StartFailureThreshold = 3
+	want.StartBackoffMaxSeconds = 5
+	want.Strict = true
+	if applied := keepRestartOnlySettings(running, reloaded); !reflect.DeepEqual(*applied, want) {
+		t.Errorf("keepRestartOnlySettings() = %+v, want %+v", *applied, want)
+	}
+	if reloaded.EnclaveCIDBase != 32 {
+		t.Error("Expected the reloaded config to be left untouched")
+	}
+}
@@ -0,0 +1,54 @@
+// Copyright 2026 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"k8s-ne-device-plugin/pkg/config"
+	"k8s-ne-device-plugin/pkg/introspection"
+
+	"github.com/golang/glog"
+)
+
+// runStatus queries the status endpoint of the running plugin, prints the status to stdout and
+// returns the exit code: 1 if the plugin can't be queried, 2 on usage errors.
+func runStatus(args []string, stdout io.Writer) int {
+	fs := subcommandFlagSet("status")
+	output := fs.String("output", "table", "Output format: table or json")
+	// the status address is taken from the config of the plugin, e.g. its environment
+	configLoader := config.NewLoader()
+	configLoader.RegisterFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *output != "table" && *output != "json" {
+		fmt.Fprintf(fs.Output(), "unknown output format %q\n", *output)
+		return 2
+	}
+
+	pluginConfig, _ := configLoader.Inspect()
+	if pluginConfig.StatusAddress == "" {
+		fmt.Fprintln(fs.Output(), "the status endpoint is disabled, set -status-address")
+		return 2
+	}
+
+	status, err := introspection.Fetch(pluginConfig.StatusAddress)
+	if err != nil {
+		glog.Errorf("Error while querying the plugin status on %s: %v", pluginConfig.StatusAddress, err)
+		glog.Flush()
+		return 1
+	}
+
+	if *output == "json" {
+		err = status.WriteJSON(stdout)
+	} else {
+		err = status.WriteTable(stdout)
+	}
+	if err != nil {
+		glog.Errorf("Error while writing the plugin status: %v", err)
+		return 1
+	}
+	return 0
+}
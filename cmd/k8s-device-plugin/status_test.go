@@ -0,0 +1,63 @@
+// Copyright 2026 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"k8s-ne-device-plugin/pkg/introspection"
+	"net"
+	"strings"
+	"testing"
+)
+
+type fakeReporter struct {
+	status introspection.PluginStatus
+}
+
+func (f *fakeReporter) Status() introspection.PluginStatus { return f.status }
+
+func TestStatus(t *testing.T) {
+	reporter := &fakeReporter{status: introspection.PluginStatus{
+		ResourceName: "aws.ec2.nitro/nitro_enclaves",
+		State:        "Running",
+		Devices:      []introspection.Device{{ID: "nitro_enclaves_0", Health: "Healthy"}},
+	}}
+	server, err := introspection.Serve("127.0.0.1:0", reporter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	var out bytes.Buffer
+	if code := runStatus([]string{"-status-address", server.Addr}, &out); code != 0 || !strings.Contains(out.String(), "nitro_enclaves_0") {
+		t.Errorf("runStatus() = %v, want a table:\n%s", code, out.String())
+	}
+
+	out.Reset()
+	if code := runStatus([]string{"-status-address", server.Addr, "-output", "json"}, &out); code != 0 {
+		t.Fatalf("runStatus() = %v, want 0", code)
+	}
+	status := introspection.Status{}
+	if err = json.Unmarshal(out.Bytes(), &status); err != nil || len(status.Plugins) != 1 || status.Plugins[0].State != "Running" {
+		t.Errorf("runStatus() printed %s (%v), want the status as JSON", out.String(), err)
+	}
+
+	// nothing is served on a closed listener
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	listener.Close()
+	if code := runStatus([]string{"-status-address", listener.Addr().String()}, &out); code != 1 {
+		t.Errorf("runStatus() = %v without a running plugin, want 1", code)
+	}
+
+	if code := runStatus([]string{"-output", "yaml"}, &out); code != 2 {
+		t.Errorf("runStatus() = %v with an unknown output format, want 2", code)
+	}
+	if code := runStatus([]string{"-status-address", ""}, &out); code != 2 {
+		t.Errorf("runStatus() = %v with the status endpoint disabled, want 2", code)
+	}
+}
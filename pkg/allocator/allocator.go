@@ -0,0 +1,98 @@
+// Copyright 2026 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package allocator reads the configuration of the Nitro Enclaves allocator service
+// (/etc/nitro_enclaves/allocator.yaml), which reserves the CPUs and hugepage memory
+// available to enclaves on a node.
+package allocator
+
+import (
+	"errors"
+	"fmt"
+	"k8s-ne-device-plugin/pkg/cpulist"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	DefaultConfigPath = "/etc/nitro_enclaves/allocator.yaml"
+
+	mib = 1024 * 1024
+)
+
+// Config holds the enclave resources requested from the allocator service.
+// Either CPUCount or CPUPool is set, never both.
+type Config struct {
+	MemoryMiB int    `yaml:"memory_mib"`
+	CPUCount  int    `yaml:"cpu_count"`
+	CPUPool   string `yaml:"cpu_pool"`
+}
+
+// LoadConfig reads and validates an allocator configuration file.
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	config := &Config{}
+	if err = yaml.Unmarshal(data, config); err != nil {
+		return nil, fmt.Errorf("error parsing allocator config %s: %w", path, err)
+	}
+	if err = config.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid allocator config %s: %w", path, err)
+	}
+
+	return config, nil
+}
+
+// Validate checks the configuration the same way the allocator service does.
+func (c *Config) Validate() error {
+	if c.CPUCount != 0 && c.CPUPool != "" {
+		return errors.New("cpu_count and cpu_pool are mutually exclusive")
+	}
+	if c.CPUCount < 0 {
+		return fmt.Errorf("cpu_count must not be negative: %d", c.CPUCount)
+	}
+	if c.MemoryMiB < 0 {
+		return fmt.Errorf("memory_mib must not be negative: %d", c.MemoryMiB)
+	}
+	if _, err := cpulist.Parse(c.CPUPool); err != nil {
+		return fmt.Errorf("invalid cpu_pool: %w", err)
+	}
+	return nil
+}
+
+// CPUs returns the explicitly configured CPU pool. The set is empty if the pool is only
+// given as a CPU count.
+func (c *Config) CPUs() cpulist.CPUSet {
+	cpus, err := cpulist.Parse(c.CPUPool)
+	if err != nil {
+		return cpulist.New()
+	}
+	return cpus
+}
+
+// CheckCPUs cross-checks the configured CPUs against the enclave CPU pool found on the node.
+func (c *Config) CheckCPUs(pool cpulist.CPUSet) error {
+	if c.CPUPool != "" {
+		if configured := c.CPUs(); !configured.Equals(pool) {
+			return fmt.Errorf("allocator cpu_pool %q does not match the enclave CPU pool %q", configured, pool)
+		}
+		return nil
+	}
+	if c.CPUCount != pool.Size() {
+		return fmt.Errorf("allocator cpu_count %d does not match the %d CPUs of the enclave CPU pool %q", c.CPUCount, pool.Size(), pool)
+	}
+	return nil
+}
+
+// CheckMemory cross-checks the configured memory against the reserved hugepage memory found
+// on the node. The allocator rounds up to whole hugepages, thus more memory may be reserved.
+func (c *Config) CheckMemory(reservedBytes uint64) error {
+	if reservedBytes < uint64(c.MemoryMiB)*mib {
+		return fmt.Errorf("allocator memory_mib %d exceeds the %d MiB of reserved hugepages", c.MemoryMiB, reservedBytes/mib)
+	}
+	return nil
+}
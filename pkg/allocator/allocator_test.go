@@ -0,0 +1,104 @@
+// Copyright 2026 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package allocator
+
+import (
+	"k8s-ne-device-plugin/pkg/cpulist"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "allocator.yaml")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    Config
+		wantErr bool
+	}{
+		{
+			name:    "cpu count",
+			content: "---\n# How much memory to allocate for enclaves (in MiB).\nmemory_mib: 512\ncpu_count: 2\n",
+			want:    Config{MemoryMiB: 512, CPUCount: 2},
+		},
+		{
+			name:    "cpu pool",
+			content: "memory_mib: 2048\ncpu_pool: 2,3,6-9\n",
+			want:    Config{MemoryMiB: 2048, CPUPool: "2,3,6-9"},
+		},
+		{
+			name:    "single cpu pool",
+			content: "memory_mib: 2048\ncpu_pool: 3\n",
+			want:    Config{MemoryMiB: 2048, CPUPool: "3"},
+		},
+		{
+			name:    "cpu count and pool",
+			content: "memory_mib: 512\ncpu_count: 2\ncpu_pool: 2-3\n",
+			wantErr: true,
+		},
+		{
+			name:    "invalid cpu pool",
+			content: "memory_mib: 512\ncpu_pool: 3-2\n",
+			wantErr: true,
+		},
+		{
+			name:    "malformed yaml",
+			content: "memory_mib: [\n",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := LoadConfig(writeConfig(t, tt.content))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("LoadConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && *got != tt.want {
+				t.Errorf("LoadConfig() = %+v, want %+v", *got, tt.want)
+			}
+		})
+	}
+
+	if _, err := LoadConfig(filepath.Join(t.TempDir(), "missing.yaml")); err == nil {
+		t.Error("Expected LoadConfig() to fail for a missing file")
+	}
+}
+
+func TestCheckCPUs(t *testing.T) {
+	pool := cpulist.New(2, 3)
+
+	if err := (&Config{CPUCount: 2}).CheckCPUs(pool); err != nil {
+		t.Errorf("CheckCPUs() unexpected error: %v", err)
+	}
+	if err := (&Config{CPUPool: "2-3"}).CheckCPUs(pool); err != nil {
+		t.Errorf("CheckCPUs() unexpected error: %v", err)
+	}
+	if err := (&Config{CPUCount: 4}).CheckCPUs(pool); err == nil {
+		t.Error("Expected CheckCPUs() to report a cpu_count mismatch")
+	}
+	if err := (&Config{CPUPool: "2,4"}).CheckCPUs(pool); err == nil {
+		t.Error("Expected CheckCPUs() to report a cpu_pool mismatch")
+	}
+}
+
+func TestCheckMemory(t *testing.T) {
+	config := &Config{MemoryMiB: 512}
+
+	if err := config.CheckMemory(1024 * mib); err != nil {
+		t.Errorf("CheckMemory() unexpected error: %v", err)
+	}
+	if err := config.CheckMemory(256 * mib); err == nil {
+		t.Error("Expected CheckMemory() to report missing hugepages")
+	}
+}
@@ -4,50 +4,557 @@
 package config
 
 import (
+	"bytes"
+	"errors"
+	"flag"
 	"fmt"
 	"github.com/golang/glog"
+	"gopkg.in/yaml.v3"
+	"io"
+	"k8s-ne-device-plugin/pkg/allocator"
+	"math"
 	"os"
+	"path/filepath"
+	"slices"
 	"strconv"
+
+	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
 )
 
+// PluginConfig holds the configuration of all device plugins. Values are taken from command line
+// flags, environment variables, an optional YAML or JSON config file and defaults, in that order
+// of precedence.
 type PluginConfig struct {
-	MaxEnclavesPerNode      int
-	EnclaveCPUAdvertisement bool
+	MaxEnclavesPerNode         int  `yaml:"maxEnclavesPerNode" json:"maxEnclavesPerNode"`
+	EnclaveCPUAdvertisement    bool `yaml:"enclaveCPUAdvertisement" json:"enclaveCPUAdvertisement"`
+	EnclaveMemoryAdvertisement bool `yaml:"enclaveMemoryAdvertisement" json:"enclaveMemoryAdvertisement"`
+	// EnclaveMemoryBlockSizeMiB is the amount of hugepage memory represented by a single
+	// "aws.ec2.nitro/nitro_enclaves_memory" device.
+	EnclaveMemoryBlockSizeMiB int `yaml:"enclaveMemoryBlockSizeMiB" json:"enclaveMemoryBlockSizeMiB"`
+	// EnclaveCIDBase is the first vsock CID handed to "aws.ec2.nitro/nitro_enclaves" allocations,
+	// see EnclaveCIDsPerSlot. Zero, the default, disables handing out CIDs.
+	EnclaveCIDBase int `yaml:"enclaveCIDBase" json:"enclaveCIDBase"`
+	// EnclaveCIDsPerSlot is the number of consecutive vsock CIDs reserved for each
+	// "aws.ec2.nitro/nitro_enclaves" device, starting at EnclaveCIDBase for the first one.
+	EnclaveCIDsPerSlot int `yaml:"enclaveCIDsPerSlot" json:"enclaveCIDsPerSlot"`
+	// EnclaveExtraDevices are device files added to every "aws.ec2.nitro/nitro_enclaves"
+	// allocation next to the Nitro Enclaves device.
+	EnclaveExtraDevices []DeviceSpec `yaml:"enclaveExtraDevices" json:"enclaveExtraDevices"`
+	// EnclaveExtraMounts are host paths mounted into every "aws.ec2.nitro/nitro_enclaves" allocation.
+	EnclaveExtraMounts []Mount `yaml:"enclaveExtraMounts" json:"enclaveExtraMounts"`
+	// AllocatorConfigPath points to the Nitro Enclaves allocator config, used to cross-check
+	// and, if sysfs can't be read, to derive the enclave CPU and memory pools.
+	AllocatorConfigPath string `yaml:"allocatorConfigPath" json:"allocatorConfigPath"`
+	// MetricsAddress is the address serving Prometheus metrics, e.g. ":9102". Metrics are disabled if empty.
+	MetricsAddress string `yaml:"metricsAddress" json:"metricsAddress"`
+	// ProbeAddress is the address serving the /healthz and /readyz probes. Probes are disabled if empty.
+	ProbeAddress string `yaml:"probeAddress" json:"probeAddress"`
+	// StatusAddress is the address serving the read-only plugin status queried by the status
+	// subcommand. The status endpoint is disabled if empty.
+	StatusAddress string `yaml:"statusAddress" json:"statusAddress"`
+	// StartFailureThreshold is the number of consecutive plugin start failures after which the
+	// liveness probe fails. Zero disables the check.
+	StartFailureThreshold int `yaml:"startFailureThreshold" json:"startFailureThreshold"`
+	// StartBackoffMaxSeconds caps the exponentially growing delay between plugin start retries.
+	// Zero selects the default of one minute.
+	StartBackoffMaxSeconds int `yaml:"startBackoffMaxSeconds" json:"startBackoffMaxSeconds"`
+	// MonitorMode selects how the kubelet and plugin sockets are watched, see WatchModes.
+	MonitorMode string `yaml:"monitorMode" json:"monitorMode"`
+	// MonitorPollIntervalSeconds is the interval of checking the sockets when polling.
+	MonitorPollIntervalSeconds int `yaml:"monitorPollIntervalSeconds" json:"monitorPollIntervalSeconds"`
+	// KubeletRootDir is the root directory of the kubelet, as set by its --root-dir flag.
+	KubeletRootDir string `yaml:"kubeletRootDir" json:"kubeletRootDir"`
+	// DevicePluginDir is the directory of the plugin sockets and the kubelet registration
+	// socket. Defaults to "device-plugins" within KubeletRootDir.
+	DevicePluginDir string `yaml:"devicePluginDir" json:"devicePluginDir"`
+	// DevRoot is where the plugin reads the device files, e.g. a mount of the host's /dev.
+	DevRoot string `yaml:"devRoot" json:"devRoot"`
+	// HostDevRoot is the location of the device files on the host, as handed to the kubelet
+	// for mounting them into containers.
+	HostDevRoot string `yaml:"hostDevRoot" json:"hostDevRoot"`
+	// SysRoot is where the plugin reads sysfs, e.g. a fake sysfs tree for testing.
+	SysRoot string `yaml:"sysRoot" json:"sysRoot"`
+	// Strict turns invalid values into errors instead of replacing them with defaults.
+	Strict bool `yaml:"strict" json:"strict"`
 }
 
 const (
 	// EC2 instance with nitro_option enabled, can support upto 4 enclaves.
 	// https://docs.aws.amazon.com/enclaves/latest/user/multiple-enclaves.html
 	maxEnclavesPerInstance = 4
+
+	// Enclave vsock CIDs 0 to 3 are reserved for the hypervisor, the loopback, the host and the
+	// parent instance, 0xFFFFFFFF is VMADDR_CID_ANY.
+	minEnclaveCID = 4
+	maxEnclaveCID = math.MaxUint32 - 1
+
+	defaultEnclaveMemoryBlockSizeMiB  = 256
+	defaultEnclaveCIDBase             = 0
+	defaultEnclaveCIDsPerSlot         = 1
+	defaultProbeAddress               = ":8081"
+	defaultStatusAddress              = "127.0.0.1:8083"
+	defaultStartFailureThreshold      = 10
+	defaultStartBackoffMaxSeconds     = 60
+	defaultMonitorPollIntervalSeconds = 5
+
+	defaultKubeletRootDir = "/var/lib/kubelet"
+	defaultDevRoot        = "/dev"
+	defaultSysRoot        = "/sys"
+
+	configFileEnv  = "PLUGIN_CONFIG_FILE"
+	configFileFlag = "config"
 )
 
+// WatchMode selects how the plugin supervisor detects changes of the kubelet and plugin sockets.
+type WatchMode string
+
+const (
+	// WatchModeAuto uses fsnotify and falls back to polling if inotify can't be used, e.g.
+	// because the inotify limits of the node are exhausted.
+	WatchModeAuto WatchMode = "auto"
+	// WatchModeFsnotify uses fsnotify only and fails if inotify can't be used.
+	WatchModeFsnotify WatchMode = "fsnotify"
+	// WatchModePoll periodically checks the sockets.
+	WatchModePoll WatchMode = "poll"
+)
+
+// WatchModes lists all valid watch modes.
+var WatchModes = []WatchMode{WatchModeAuto, WatchModeFsnotify, WatchModePoll}
+
+// setting binds a PluginConfig field to its environment variable and command line flag.
+type setting struct {
+	env   string
+	flag  string
+	usage string
+	set   func(c *PluginConfig, value string) error
+}
+
+func intSetting(field func(c *PluginConfig) *int) func(c *PluginConfig, value string) error {
+	return func(c *PluginConfig, value string) error {
+		v, err := strconv.Atoi(value)
+		if err != nil {
+			return err
+		}
+		*field(c) = v
+		return nil
+	}
+}
+
+func boolSetting(field func(c *PluginConfig) *bool) func(c *PluginConfig, value string) error {
+	return func(c *PluginConfig, value string) error {
+		v, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		*field(c) = v
+		return nil
+	}
+}
+
+func stringSetting(field func(c *PluginConfig) *string) func(c *PluginConfig, value string) error {
+	return func(c *PluginConfig, value string) error {
+		*field(c) = value
+		return nil
+	}
+}
+
+var settings = []setting{
+	{
+		env:   "MAX_ENCLAVES_PER_NODE",
+		flag:  "max-enclaves-per-node",
+		usage: "Number of aws.ec2.nitro/nitro_enclaves devices to advertise (1-4)",
+		set:   intSetting(func(c *PluginConfig) *int { return &c.MaxEnclavesPerNode }),
+	},
+	{
+		env:   "ENCLAVE_CPU_ADVERTISEMENT",
+		flag:  "enclave-cpu-advertisement",
+		usage: "Advertise enclave CPUs as aws.ec2.nitro/nitro_enclaves_cpus",
+		set:   boolSetting(func(c *PluginConfig) *bool { return &c.EnclaveCPUAdvertisement }),
+	},
+	{
+		env:   "ENCLAVE_MEMORY_ADVERTISEMENT",
+		flag:  "enclave-memory-advertisement",
+		usage: "Advertise enclave hugepage memory as aws.ec2.nitro/nitro_enclaves_memory",
+		set:   boolSetting(func(c *PluginConfig) *bool { return &c.EnclaveMemoryAdvertisement }),
+	},
+	{
+		env:   "ENCLAVE_MEMORY_BLOCK_SIZE_MIB",
+		flag:  "enclave-memory-block-size-mib",
+		usage: "Size of a single aws.ec2.nitro/nitro_enclaves_memory device in MiB",
+		set:   intSetting(func(c *PluginConfig) *int { return &c.EnclaveMemoryBlockSizeMiB }),
+	},
+	{
+		env:   "ENCLAVE_CID_BASE",
+		flag:  "enclave-cid-base",
+		usage: "First vsock CID handed to aws.ec2.nitro/nitro_enclaves allocations, e.g. 16 (0 disables)",
+		set:   intSetting(func(c *PluginConfig) *int { return &c.EnclaveCIDBase }),
+	},
+	{
+		env:   "ENCLAVE_CIDS_PER_SLOT",
+		flag:  "enclave-cids-per-slot",
+		usage: "Number of vsock CIDs reserved for each aws.ec2.nitro/nitro_enclaves device",
+		set:   intSetting(func(c *PluginConfig) *int { return &c.EnclaveCIDsPerSlot }),
+	},
+	{
+		env:   "ENCLAVE_EXTRA_DEVICES",
+		flag:  "enclave-extra-devices",
+		usage: "Comma separated host[:container[:permissions]] device files added to aws.ec2.nitro/nitro_enclaves allocations",
+		set:   deviceSpecsSetting,
+	},
+	{
+		env:   "ENCLAVE_EXTRA_MOUNTS",
+		flag:  "enclave-extra-mounts",
+		usage: "Comma separated host[:container[:ro|rw]] paths mounted into aws.ec2.nitro/nitro_enclaves allocations",
+		set:   mountsSetting,
+	},
+	{
+		env:   "ALLOCATOR_CONFIG_PATH",
+		flag:  "allocator-config-path",
+		usage: "Path of the Nitro Enclaves allocator config",
+		set:   stringSetting(func(c *PluginConfig) *string { return &c.AllocatorConfigPath }),
+	},
+	{
+		env:   "METRICS_ADDRESS",
+		flag:  "metrics-address",
+		usage: "Address to serve Prometheus metrics on, e.g. :9102 (disabled if empty)",
+		set:   stringSetting(func(c *PluginConfig) *string { return &c.MetricsAddress }),
+	},
+	{
+		env:   "PROBE_ADDRESS",
+		flag:  "probe-address",
+		usage: "Address to serve the /healthz and /readyz probes on (disabled if empty)",
+		set:   stringSetting(func(c *PluginConfig) *string { return &c.ProbeAddress }),
+	},
+	{
+		env:   "STATUS_ADDRESS",
+		flag:  "status-address",
+		usage: "Address to serve the read-only plugin status on (disabled if empty)",
+		set:   stringSetting(func(c *PluginConfig) *string { return &c.StatusAddress }),
+	},
+	{
+		env:   "START_FAILURE_THRESHOLD",
+		flag:  "start-failure-threshold",
+		usage: "Consecutive plugin start failures after which the liveness probe fails (0 disables)",
+		set:   intSetting(func(c *PluginConfig) *int { return &c.StartFailureThreshold }),
+	},
+	{
+		env:   "START_BACKOFF_MAX_SECONDS",
+		flag:  "start-backoff-max-seconds",
+		usage: "Upper bound of the delay between plugin start retries in seconds",
+		set:   intSetting(func(c *PluginConfig) *int { return &c.StartBackoffMaxSeconds }),
+	},
+	{
+		env:   "MONITOR_MODE",
+		flag:  "monitor-mode",
+		usage: "How to watch the kubelet and plugin sockets: auto, fsnotify or poll",
+		set:   stringSetting(func(c *PluginConfig) *string { return &c.MonitorMode }),
+	},
+	{
+		env:   "MONITOR_POLL_INTERVAL_SECONDS",
+		flag:  "monitor-poll-interval-seconds",
+		usage: "Interval of checking the kubelet and plugin sockets when polling, in seconds",
+		set:   intSetting(func(c *PluginConfig) *int { return &c.MonitorPollIntervalSeconds }),
+	},
+	{
+		env:   "KUBELET_ROOT_DIR",
+		flag:  "kubelet-root-dir",
+		usage: "Root directory of the kubelet (its --root-dir)",
+		set:   stringSetting(func(c *PluginConfig) *string { return &c.KubeletRootDir }),
+	},
+	{
+		env:   "DEVICE_PLUGIN_DIR",
+		flag:  "device-plugin-dir",
+		usage: "Directory of the device plugin and kubelet sockets (default <kubelet-root-dir>/device-plugins)",
+		set:   stringSetting(func(c *PluginConfig) *string { return &c.DevicePluginDir }),
+	},
+	{
+		env:   "DEV_ROOT",
+		flag:  "dev-root",
+		usage: "Directory the plugin reads device files from",
+		set:   stringSetting(func(c *PluginConfig) *string { return &c.DevRoot }),
+	},
+	{
+		env:   "HOST_DEV_ROOT",
+		flag:  "host-dev-root",
+		usage: "Directory of the device files on the host, mounted into containers",
+		set:   stringSetting(func(c *PluginConfig) *string { return &c.HostDevRoot }),
+	},
+	{
+		env:   "SYS_ROOT",
+		flag:  "sys-root",
+		usage: "Directory the plugin reads sysfs from",
+		set:   stringSetting(func(c *PluginConfig) *string { return &c.SysRoot }),
+	},
+	{
+		env:   "STRICT_CONFIG",
+		flag:  "strict-config",
+		usage: "Fail on invalid configuration values instead of falling back to defaults",
+		set:   boolSetting(func(c *PluginConfig) *bool { return &c.Strict }),
+	},
+}
+
+// Defaults returns the configuration used when no other source sets a value.
+func Defaults() *PluginConfig {
+	return &PluginConfig{
+		MaxEnclavesPerNode:         maxEnclavesPerInstance,
+		EnclaveMemoryBlockSizeMiB:  defaultEnclaveMemoryBlockSizeMiB,
+		EnclaveCIDBase:             defaultEnclaveCIDBase,
+		EnclaveCIDsPerSlot:         defaultEnclaveCIDsPerSlot,
+		AllocatorConfigPath:        allocator.DefaultConfigPath,
+		ProbeAddress:               defaultProbeAddress,
+		StatusAddress:              defaultStatusAddress,
+		StartFailureThreshold:      defaultStartFailureThreshold,
+		StartBackoffMaxSeconds:     defaultStartBackoffMaxSeconds,
+		MonitorMode:                string(WatchModeAuto),
+		MonitorPollIntervalSeconds: defaultMonitorPollIntervalSeconds,
+		KubeletRootDir:             defaultKubeletRootDir,
+		DevRoot:                    defaultDevRoot,
+		HostDevRoot:                defaultDevRoot,
+		SysRoot:                    defaultSysRoot,
+	}
+}
+
+// orDefault returns value, or def if value is empty.
+func orDefault(value, def string) string {
+	if value == "" {
+		return def
+	}
+	return value
+}
+
+// DevicePluginPath returns the directory of the device plugin sockets.
+func (c *PluginConfig) DevicePluginPath() string {
+	if c.DevicePluginDir != "" {
+		return c.DevicePluginDir
+	}
+	return filepath.Join(orDefault(c.KubeletRootDir, defaultKubeletRootDir), "device-plugins")
+}
+
+// KubeletSocketPath returns the path of the kubelet registration socket.
+func (c *PluginConfig) KubeletSocketPath() string {
+	return filepath.Join(c.DevicePluginPath(), filepath.Base(pluginapi.KubeletSocket))
+}
+
+// DevPath returns the path the plugin reads the given device file from.
+func (c *PluginConfig) DevPath(name string) string {
+	return filepath.Join(orDefault(c.DevRoot, defaultDevRoot), name)
+}
+
+// HostDevPath returns the path of the given device file on the host.
+func (c *PluginConfig) HostDevPath(name string) string {
+	return filepath.Join(orDefault(c.HostDevRoot, defaultDevRoot), name)
+}
+
+// SysPath returns the path the plugin reads the given sysfs file from.
+func (c *PluginConfig) SysPath(elem ...string) string {
+	return filepath.Join(append([]string{orDefault(c.SysRoot, defaultSysRoot)}, elem...)...)
+}
+
+// Validate checks the configuration. Invalid values are replaced by their defaults, unless
+// the configuration is strict, in which case they are left untouched. Invalid extra enclave
+// devices and mounts have no default and are always left untouched.
 func (c *PluginConfig) Validate() error {
+	var errs []error
 	if c.MaxEnclavesPerNode <= 0 || c.MaxEnclavesPerNode > maxEnclavesPerInstance {
-		c.MaxEnclavesPerNode = maxEnclavesPerInstance
-		return fmt.Errorf("max devices per node must be greater than 0 and smaller or equal to %v - set value to max", maxEnclavesPerInstance)
+		if c.Strict {
+			errs = append(errs, fmt.Errorf("max devices per node must be greater than 0 and smaller or equal to %v", maxEnclavesPerInstance))
+		} else {
+			c.MaxEnclavesPerNode = maxEnclavesPerInstance
+			errs = append(errs, fmt.Errorf("max devices per node must be greater than 0 and smaller or equal to %v - set value to max", maxEnclavesPerInstance))
+		}
+	}
+	if c.EnclaveMemoryAdvertisement && c.EnclaveMemoryBlockSizeMiB <= 0 {
+		if c.Strict {
+			errs = append(errs, errors.New("enclave memory block size must be greater than 0"))
+		} else {
+			c.EnclaveMemoryBlockSizeMiB = defaultEnclaveMemoryBlockSizeMiB
+			errs = append(errs, fmt.Errorf("enclave memory block size must be greater than 0 - set value to %v MiB", defaultEnclaveMemoryBlockSizeMiB))
+		}
+	}
+	if c.EnclaveCIDBase != 0 && (c.EnclaveCIDBase < minEnclaveCID || c.EnclaveCIDsPerSlot <= 0 ||
+		c.EnclaveCIDBase+maxEnclavesPerInstance*c.EnclaveCIDsPerSlot-1 > maxEnclaveCID) {
+		if c.Strict {
+			errs = append(errs, fmt.Errorf("enclave CIDs must range from %v to %v with at least one CID per slot", minEnclaveCID, maxEnclaveCID))
+		} else {
+			c.EnclaveCIDBase = defaultEnclaveCIDBase
+			c.EnclaveCIDsPerSlot = defaultEnclaveCIDsPerSlot
+			errs = append(errs, fmt.Errorf("enclave CIDs must range from %v to %v with at least one CID per slot - disabled them", minEnclaveCID, maxEnclaveCID))
+		}
+	}
+	if c.StartFailureThreshold < 0 {
+		if c.Strict {
+			errs = append(errs, errors.New("start failure threshold must not be negative"))
+		} else {
+			c.StartFailureThreshold = defaultStartFailureThreshold
+			errs = append(errs, fmt.Errorf("start failure threshold must not be negative - set value to %v", defaultStartFailureThreshold))
+		}
+	}
+	if c.StartBackoffMaxSeconds < 0 {
+		if c.Strict {
+			errs = append(errs, errors.New("start backoff maximum must not be negative"))
+		} else {
+			c.StartBackoffMaxSeconds = defaultStartBackoffMaxSeconds
+			errs = append(errs, fmt.Errorf("start backoff maximum must not be negative - set value to %v seconds", defaultStartBackoffMaxSeconds))
+		}
+	}
+	if c.MonitorMode != "" && !slices.Contains(WatchModes, WatchMode(c.MonitorMode)) {
+		if c.Strict {
+			errs = append(errs, fmt.Errorf("monitor mode must be one of %v", WatchModes))
+		} else {
+			c.MonitorMode = string(WatchModeAuto)
+			errs = append(errs, fmt.Errorf("monitor mode must be one of %v - set value to %v", WatchModes, c.MonitorMode))
+		}
+	}
+	if c.MonitorPollIntervalSeconds < 0 {
+		if c.Strict {
+			errs = append(errs, errors.New("monitor poll interval must not be negative"))
+		} else {
+			c.MonitorPollIntervalSeconds = defaultMonitorPollIntervalSeconds
+			errs = append(errs, fmt.Errorf("monitor poll interval must not be negative - set value to %v seconds", defaultMonitorPollIntervalSeconds))
+		}
+	}
+	for _, dir := range []struct {
+		name  string
+		value *string
+		def   string
+	}{
+		{"kubelet root dir", &c.KubeletRootDir, defaultKubeletRootDir},
+		{"device plugin dir", &c.DevicePluginDir, ""},
+		{"dev root", &c.DevRoot, defaultDevRoot},
+		{"host dev root", &c.HostDevRoot, defaultDevRoot},
+		{"sys root", &c.SysRoot, defaultSysRoot},
+	} {
+		if *dir.value == "" || filepath.IsAbs(*dir.value) {
+			continue
+		}
+		if c.Strict {
+			errs = append(errs, fmt.Errorf("%s must be an absolute path", dir.name))
+		} else {
+			*dir.value = dir.def
+			errs = append(errs, fmt.Errorf("%s must be an absolute path - set value to %q", dir.name, dir.def))
+		}
+	}
+	if err := c.validateExtras(); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// Loader assembles the PluginConfig from all of its sources.
+type Loader struct {
+	// ConfigFile is the path of the optional YAML or JSON config file.
+	ConfigFile string
+	// flagValues holds the command line flags explicitly set by the user.
+	flagValues map[string]string
+}
+
+// NewLoader returns a Loader reading the config file named by the PLUGIN_CONFIG_FILE
+// environment variable, if any.
+func NewLoader() *Loader {
+	return &Loader{
+		ConfigFile: os.Getenv(configFileEnv),
+		flagValues: map[string]string{},
+	}
+}
+
+// RegisterFlags adds the -config flag and a flag for each configuration value to fs.
+func (l *Loader) RegisterFlags(fs *flag.FlagSet) {
+	fs.Func(configFileFlag, "Path of a YAML or JSON plugin config file (env "+configFileEnv+")", func(value string) error {
+		l.ConfigFile = value
+		return nil
+	})
+	for _, s := range settings {
+		name := s.flag
+		fs.Func(name, s.usage+" (env "+s.env+")", func(value string) error {
+			l.flagValues[name] = value
+			return nil
+		})
 	}
-	return nil
 }
-func LoadConfig() *PluginConfig {
-	// config parameters are primarily sourced via environment variables
-	config := &PluginConfig{}
 
-	var enclaveCPUAdvertisement bool
-	enclaveCPUAdvertisement, err := strconv.ParseBool(os.Getenv("ENCLAVE_CPU_ADVERTISEMENT"))
+// loadFile applies the values found in the config file onto c. Fields missing in the file are left untouched.
+func (l *Loader) loadFile(c *PluginConfig) error {
+	data, err := os.ReadFile(l.ConfigFile)
 	if err != nil {
-		glog.Errorf("error parsing ENCLAVE_CPU_ADVERTISEMENT: %v", err)
-		glog.Infof("setting ENCLAVE_CPU_ADVERTISEMENT to: %v", false)
-		enclaveCPUAdvertisement = false
+		return err
+	}
+
+	// JSON is a subset of YAML, hence a single decoder handles both formats.
+	if err = yaml.Unmarshal(data, c); err != nil {
+		return fmt.Errorf("error parsing config file %s: %w", l.ConfigFile, err)
+	}
+
+	// Report unknown fields, e.g. typos, which are silently ignored above.
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err = decoder.Decode(&PluginConfig{}); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("error parsing config file %s: %w", l.ConfigFile, err)
+	}
+
+	return nil
+}
+
+// Inspect assembles the configuration like Load, but returns all errors found along with the
+// configuration Load would fall back to, regardless of strict mode.
+func (l *Loader) Inspect() (*PluginConfig, error) {
+	config := Defaults()
+	var errs []error
+
+	if l.ConfigFile != "" {
+		if err := l.loadFile(config); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	for _, s := range settings {
+		value, ok := os.LookupEnv(s.env)
+		if !ok {
+			continue
+		}
+		if err := s.set(config, value); err != nil {
+			errs = append(errs, fmt.Errorf("error parsing %s: %w", s.env, err))
+		}
+	}
+
+	for _, s := range settings {
+		value, ok := l.flagValues[s.flag]
+		if !ok {
+			continue
+		}
+		if err := s.set(config, value); err != nil {
+			errs = append(errs, fmt.Errorf("error parsing -%s: %w", s.flag, err))
+		}
+	}
+
+	if err := config.Validate(); err != nil {
+		errs = append(errs, err)
 	}
-	config.EnclaveCPUAdvertisement = enclaveCPUAdvertisement
 
-	maxDevices, err := strconv.Atoi(os.Getenv("MAX_ENCLAVES_PER_NODE"))
+	return config, errors.Join(errs...)
+}
+
+// Load assembles the configuration from defaults, the config file, environment variables and
+// command line flags, in increasing order of precedence, and validates it. Values which can't
+// be parsed are skipped and reported. In strict mode, any such error fails the load, as do
+// invalid extra enclave devices and mounts in any mode.
+func (l *Loader) Load() (*PluginConfig, error) {
+	config, err := l.Inspect()
 	if err != nil {
-		glog.Errorf("error parsing MAX_DEVICES_PER_NODE: %v", err)
-		glog.Infof("Setting MAX_DEVICES_PER_NODE to: %v", maxEnclavesPerInstance)
-		maxDevices = maxEnclavesPerInstance
+		if config.Strict || errors.Is(err, errInvalidExtra) {
+			return nil, err
+		}
+		glog.Errorf("invalid plugin config, falling back to defaults: %v", err)
 	}
-	config.MaxEnclavesPerNode = maxDevices
 
+	return config, nil
+}
+
+// LoadConfig returns the configuration sourced from environment variables and defaults.
+func LoadConfig() *PluginConfig {
+	config, err := NewLoader().Load()
+	if err != nil {
+		glog.Errorf("error loading plugin config: %v", err)
+		config = Defaults()
+	}
 	return config
 }
@@ -1,8 +1,13 @@
 package config
 
 import (
+	"flag"
+	"k8s-ne-device-plugin/pkg/allocator"
 	"os"
+	"path/filepath"
+	"reflect"
 	"testing"
+	"time"
 )
 
 func TestValidate(t *testing.T) {
@@ -58,6 +63,36 @@ func TestValidate(t *testing.T) {
 	}
 }
 
+// Invalid enclave CID ranges fall back to the defaults, zero disables CIDs.
+func TestValidateEnclaveCIDs(t *testing.T) {
+	tests := []struct {
+		name        string
+		base        int
+		perSlot     int
+		wantErr     bool
+		wantBase    int
+		wantPerSlot int
+	}{
+		{name: "disabled", base: 0, perSlot: 0, wantBase: 0, wantPerSlot: 0},
+		{name: "range per slot", base: 100, perSlot: 8, wantBase: 100, wantPerSlot: 8},
+		{name: "reserved CID", base: 3, perSlot: 1, wantErr: true, wantBase: defaultEnclaveCIDBase, wantPerSlot: defaultEnclaveCIDsPerSlot},
+		{name: "no CID per slot", base: 16, perSlot: 0, wantErr: true, wantBase: defaultEnclaveCIDBase, wantPerSlot: defaultEnclaveCIDsPerSlot},
+		{name: "beyond the last CID", base: maxEnclaveCID - 2, perSlot: 1, wantErr: true, wantBase: defaultEnclaveCIDBase, wantPerSlot: defaultEnclaveCIDsPerSlot},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &PluginConfig{MaxEnclavesPerNode: 1, EnclaveCIDBase: tt.base, EnclaveCIDsPerSlot: tt.perSlot}
+			if err := c.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if c.EnclaveCIDBase != tt.wantBase || c.EnclaveCIDsPerSlot != tt.wantPerSlot {
+				t.Errorf("Validate() set CIDs to %v and %v per slot, want %v and %v", c.EnclaveCIDBase, c.EnclaveCIDsPerSlot, tt.wantBase, tt.wantPerSlot)
+			}
+		})
+	}
+}
+
 func TestLoadConfig(t *testing.T) {
 	tests := []struct {
 		name                 string
@@ -122,6 +157,182 @@ func TestLoadConfig(t *testing.T) {
 				t.Errorf("LoadConfig() EnclaveCPUAdvertisement = %v, want %v",
 					config.EnclaveCPUAdvertisement, tt.wantCPUAdvertisement)
 			}
+
+			if config.AllocatorConfigPath != allocator.DefaultConfigPath {
+				t.Errorf("LoadConfig() AllocatorConfigPath = %v, want %v",
+					config.AllocatorConfigPath, allocator.DefaultConfigPath)
+			}
+		})
+	}
+}
+
+// writeConfigFile writes a plugin config file into a temporary directory and returns its path.
+func writeConfigFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Error writing config file: %v", err)
+	}
+	return path
+}
+
+func TestLoaderPrecedence(t *testing.T) {
+	path := writeConfigFile(t, "config.yaml", `
+maxEnclavesPerNode: 1
+enclaveMemoryAdvertisement: true
+enclaveMemoryBlockSizeMiB: 512
+allocatorConfigPath: /tmp/allocator.yaml
+kubeletRootDir: /data/kubelet
+`)
+	t.Setenv("MAX_ENCLAVES_PER_NODE", "2")
+	t.Setenv("SYS_ROOT", "/tmp/sys")
+	t.Setenv("ENCLAVE_MEMORY_BLOCK_SIZE_MIB", "1024")
+
+	loader := NewLoader()
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	loader.RegisterFlags(fs)
+	if err := fs.Parse([]string{"-config", path, "-max-enclaves-per-node", "3"}); err != nil {
+		t.Fatalf("Error parsing flags: %v", err)
+	}
+
+	config, err := loader.Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	want := &PluginConfig{
+		MaxEnclavesPerNode:         3,
+		EnclaveMemoryAdvertisement: true,
+		EnclaveMemoryBlockSizeMiB:  1024,
+		EnclaveCIDBase:             defaultEnclaveCIDBase,
+		EnclaveCIDsPerSlot:         defaultEnclaveCIDsPerSlot,
+		AllocatorConfigPath:        "/tmp/allocator.yaml",
+		ProbeAddress:               defaultProbeAddress,
+		StatusAddress:              defaultStatusAddress,
+		StartFailureThreshold:      defaultStartFailureThreshold,
+		StartBackoffMaxSeconds:     defaultStartBackoffMaxSeconds,
+		MonitorMode:                "auto",
+		MonitorPollIntervalSeconds: defaultMonitorPollIntervalSeconds,
+		KubeletRootDir:             "/data/kubelet",
+		DevRoot:                    defaultDevRoot,
+		HostDevRoot:                defaultDevRoot,
+		SysRoot:                    "/tmp/sys",
+	}
+	if !reflect.DeepEqual(config, want) {
+		t.Errorf("Load() = %+v, want %+v", *config, *want)
+	}
+}
+
+func TestLoaderJSONFile(t *testing.T) {
+	loader := NewLoader()
+	loader.ConfigFile = writeConfigFile(t, "config.json", `{"maxEnclavesPerNode": 2, "enclaveCPUAdvertisement": true}`)
+
+	config, err := loader.Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if config.MaxEnclavesPerNode != 2 || !config.EnclaveCPUAdvertisement {
+		t.Errorf("Load() = %+v, want values from the JSON file", *config)
+	}
+	if config.AllocatorConfigPath != allocator.DefaultConfigPath {
+		t.Errorf("Load() AllocatorConfigPath = %v, want %v", config.AllocatorConfigPath, allocator.DefaultConfigPath)
+	}
+}
+
+func TestLoaderStrict(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{name: "out of range value", content: "strict: true\nmaxEnclavesPerNode: 5\n"},
+		{name: "unknown field", content: "strict: true\nmaxEnclavePerNode: 2\n"},
+		{name: "malformed value", content: "strict: true\nmaxEnclavesPerNode: two\n"},
+		{name: "unknown monitor mode", content: "strict: true\nmonitorMode: inotify\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loader := NewLoader()
+			loader.ConfigFile = writeConfigFile(t, "config.yaml", tt.content)
+			if _, err := loader.Load(); err == nil {
+				t.Errorf("Load() expected an error in strict mode")
+			}
 		})
 	}
+
+	// without strict mode, the same out of range value is clamped
+	loader := NewLoader()
+	loader.ConfigFile = writeConfigFile(t, "config.yaml", "maxEnclavesPerNode: 5\n")
+	config, err := loader.Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if config.MaxEnclavesPerNode != maxEnclavesPerInstance {
+		t.Errorf("Load() MaxEnclavesPerNode = %v, want %v", config.MaxEnclavesPerNode, maxEnclavesPerInstance)
+	}
+}
+
+func TestWatchReloadsOnFileChange(t *testing.T) {
+	loader := NewLoader()
+	loader.ConfigFile = writeConfigFile(t, "config.yaml", "maxEnclavesPerNode: 1\n")
+
+	stop := make(chan interface{})
+	defer close(stop)
+	changes := make(chan *PluginConfig, 1)
+	go loader.Watch(stop, func(config *PluginConfig) {
+		select {
+		case changes <- config:
+		default:
+		}
+	})
+
+	// give the watcher time to start before changing the file
+	time.Sleep(100 * time.Millisecond)
+	if err := os.WriteFile(loader.ConfigFile, []byte("maxEnclavesPerNode: 3\n"), 0644); err != nil {
+		t.Fatalf("Error updating config file: %v", err)
+	}
+
+	select {
+	case config := <-changes:
+		if config.MaxEnclavesPerNode != 3 {
+			t.Errorf("Reloaded MaxEnclavesPerNode = %v, want 3", config.MaxEnclavesPerNode)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Timed out waiting for the config to be reloaded")
+	}
+}
+
+func TestPaths(t *testing.T) {
+	// unset roots fall back to the defaults
+	config := &PluginConfig{}
+	if path := config.KubeletSocketPath(); path != "/var/lib/kubelet/device-plugins/kubelet.sock" {
+		t.Errorf("KubeletSocketPath() = %v, want /var/lib/kubelet/device-plugins/kubelet.sock", path)
+	}
+	if path := config.DevPath("nitro_enclaves"); path != "/dev/nitro_enclaves" {
+		t.Errorf("DevPath() = %v, want /dev/nitro_enclaves", path)
+	}
+	if path := config.SysPath("devices/system/cpu", "offline"); path != "/sys/devices/system/cpu/offline" {
+		t.Errorf("SysPath() = %v, want /sys/devices/system/cpu/offline", path)
+	}
+
+	config = &PluginConfig{KubeletRootDir: "/data/kubelet", DevRoot: "/host/dev", HostDevRoot: "/dev"}
+	if path := config.DevicePluginPath(); path != "/data/kubelet/device-plugins" {
+		t.Errorf("DevicePluginPath() = %v, want /data/kubelet/device-plugins", path)
+	}
+	if path := config.DevPath("nitro_enclaves"); path != "/host/dev/nitro_enclaves" {
+		t.Errorf("DevPath() = %v, want /host/dev/nitro_enclaves", path)
+	}
+	if path := config.HostDevPath("nitro_enclaves"); path != "/dev/nitro_enclaves" {
+		t.Errorf("HostDevPath() = %v, want /dev/nitro_enclaves", path)
+	}
+
+	config.DevicePluginDir = "/run/device-plugins"
+	if path := config.KubeletSocketPath(); path != "/run/device-plugins/kubelet.sock" {
+		t.Errorf("KubeletSocketPath() = %v, want /run/device-plugins/kubelet.sock", path)
+	}
+
+	config = &PluginConfig{Strict: true, MaxEnclavesPerNode: 1, SysRoot: "sys"}
+	if config.Validate() == nil {
+		t.Error("Expected a relative sys root to be rejected")
+	}
 }
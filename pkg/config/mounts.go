@@ -0,0 +1,167 @@
+// Copyright 2026 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const defaultDevicePermissions = "rw"
+
+// DeviceSpec is a device file added to every "aws.ec2.nitro/nitro_enclaves" allocation, e.g.
+// /dev/vsock.
+type DeviceSpec struct {
+	HostPath string `yaml:"hostPath" json:"hostPath"`
+	// ContainerPath defaults to HostPath.
+	ContainerPath string `yaml:"containerPath,omitempty" json:"containerPath,omitempty"`
+	// Permissions are the cgroup device permissions, any of "r", "w" and "m". Defaults to "rw".
+	Permissions string `yaml:"permissions,omitempty" json:"permissions,omitempty"`
+}
+
+// Mount is a host directory or file added to every "aws.ec2.nitro/nitro_enclaves" allocation,
+// e.g. /var/log/nitro_enclaves.
+type Mount struct {
+	HostPath string `yaml:"hostPath" json:"hostPath"`
+	// ContainerPath defaults to HostPath.
+	ContainerPath string `yaml:"containerPath,omitempty" json:"containerPath,omitempty"`
+	ReadOnly      bool   `yaml:"readOnly,omitempty" json:"readOnly,omitempty"`
+}
+
+// Container returns the path of the device within the container.
+func (d DeviceSpec) Container() string {
+	return orDefault(d.ContainerPath, d.HostPath)
+}
+
+// Perms returns the permissions of the device.
+func (d DeviceSpec) Perms() string {
+	return orDefault(d.Permissions, defaultDevicePermissions)
+}
+
+// Container returns the path of the mount within the container.
+func (m Mount) Container() string {
+	return orDefault(m.ContainerPath, m.HostPath)
+}
+
+// splitPaths splits a comma separated list of host[:container[:option]] entries, like the
+// --device and --volume flags of docker.
+func splitPaths(value string) ([][]string, error) {
+	entries := [][]string{}
+	for _, entry := range strings.Split(value, ",") {
+		if entry = strings.TrimSpace(entry); entry == "" {
+			continue
+		}
+		fields := strings.Split(entry, ":")
+		if len(fields) > 3 || fields[0] == "" {
+			return nil, fmt.Errorf("invalid entry %q, want host[:container[:option]]", entry)
+		}
+		entries = append(entries, fields)
+	}
+	return entries, nil
+}
+
+// deviceSpecsSetting parses a list of host[:container[:permissions]] device files.
+func deviceSpecsSetting(c *PluginConfig, value string) error {
+	entries, err := splitPaths(value)
+	if err != nil {
+		return err
+	}
+	devices := []DeviceSpec{}
+	for _, fields := range entries {
+		device := DeviceSpec{HostPath: fields[0]}
+		if len(fields) > 1 {
+			device.ContainerPath = fields[1]
+		}
+		if len(fields) > 2 {
+			device.Permissions = fields[2]
+		}
+		devices = append(devices, device)
+	}
+	c.EnclaveExtraDevices = devices
+	return nil
+}
+
+// mountsSetting parses a list of host[:container[:ro|rw]] mounts.
+func mountsSetting(c *PluginConfig, value string) error {
+	entries, err := splitPaths(value)
+	if err != nil {
+		return err
+	}
+	mounts := []Mount{}
+	for _, fields := range entries {
+		mount := Mount{HostPath: fields[0]}
+		if len(fields) > 1 {
+			mount.ContainerPath = fields[1]
+		}
+		if len(fields) > 2 {
+			switch fields[2] {
+			case "ro":
+				mount.ReadOnly = true
+			case "rw":
+			default:
+				return fmt.Errorf("invalid mount option %q, want ro or rw", fields[2])
+			}
+		}
+		mounts = append(mounts, mount)
+	}
+	c.EnclaveExtraMounts = mounts
+	return nil
+}
+
+// validPermissions reports whether perms is a combination of the cgroup device permissions.
+func validPermissions(perms string) bool {
+	for i, p := range perms {
+		if !strings.ContainsRune("rwm", p) || strings.ContainsRune(perms[i+1:], p) {
+			return false
+		}
+	}
+	return perms != ""
+}
+
+// pluginPath returns the path the plugin sees the given host path at. Paths below HostDevRoot
+// are found below DevRoot, others at the same path, e.g. through a hostPath volume.
+func (c *PluginConfig) pluginPath(hostPath string) string {
+	if rel, err := filepath.Rel(orDefault(c.HostDevRoot, defaultDevRoot), hostPath); err == nil && filepath.IsLocal(rel) {
+		return c.DevPath(rel)
+	}
+	return hostPath
+}
+
+// checkHostPath returns an error if hostPath is not absolute or does not exist.
+func (c *PluginConfig) checkHostPath(hostPath, containerPath string) error {
+	if !filepath.IsAbs(hostPath) || !filepath.IsAbs(containerPath) {
+		return fmt.Errorf("%s:%s must be absolute paths", hostPath, containerPath)
+	}
+	if _, err := os.Stat(c.pluginPath(hostPath)); err != nil {
+		return err
+	}
+	return nil
+}
+
+// errInvalidExtra marks invalid extra devices and mounts, which fail the load even if the
+// configuration is not strict: enclave launchers depend on them, and no default can stand in.
+var errInvalidExtra = errors.New("invalid extra enclave")
+
+// validateExtras checks the extra device files and mounts of enclave allocations.
+func (c *PluginConfig) validateExtras() error {
+	var errs []error
+	for _, device := range c.EnclaveExtraDevices {
+		err := c.checkHostPath(device.HostPath, device.Container())
+		if err == nil && !validPermissions(device.Perms()) {
+			err = fmt.Errorf("invalid permissions %q of %s, want any of r, w and m", device.Permissions, device.HostPath)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%w device: %w", errInvalidExtra, err))
+		}
+	}
+	for _, mount := range c.EnclaveExtraMounts {
+		if err := c.checkHostPath(mount.HostPath, mount.Container()); err != nil {
+			errs = append(errs, fmt.Errorf("%w mount: %w", errInvalidExtra, err))
+		}
+	}
+	return errors.Join(errs...)
+}
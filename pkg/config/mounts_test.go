@@ -0,0 +1,107 @@
+// Copyright 2026 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestExtraDevicesAndMountsFlags(t *testing.T) {
+	logDir := t.TempDir()
+	devDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(devDir, "vsock"), nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	loader := NewLoader()
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	loader.RegisterFlags(fs)
+	if err := fs.Parse([]string{
+		"-dev-root", devDir,
+		"-enclave-extra-devices", "/dev/vsock, /dev/vsock:/dev/enclave-vsock:r",
+		"-enclave-extra-mounts", logDir + "," + logDir + ":/var/log/nitro_enclaves:ro",
+	}); err != nil {
+		t.Fatalf("Error parsing flags: %v", err)
+	}
+
+	// /dev/vsock is found below the dev root
+	config, err := loader.Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	wantDevices := []DeviceSpec{{HostPath: "/dev/vsock"}, {HostPath: "/dev/vsock", ContainerPath: "/dev/enclave-vsock", Permissions: "r"}}
+	if !reflect.DeepEqual(config.EnclaveExtraDevices, wantDevices) {
+		t.Errorf("EnclaveExtraDevices = %+v, want %+v", config.EnclaveExtraDevices, wantDevices)
+	}
+	wantMounts := []Mount{{HostPath: logDir}, {HostPath: logDir, ContainerPath: "/var/log/nitro_enclaves", ReadOnly: true}}
+	if !reflect.DeepEqual(config.EnclaveExtraMounts, wantMounts) {
+		t.Errorf("EnclaveExtraMounts = %+v, want %+v", config.EnclaveExtraMounts, wantMounts)
+	}
+	if device := config.EnclaveExtraDevices[0]; device.Container() != "/dev/vsock" || device.Perms() != "rw" {
+		t.Errorf("Device defaults = %v and %v, want /dev/vsock and rw", device.Container(), device.Perms())
+	}
+
+	c := &PluginConfig{}
+	for _, value := range []string{"/a:/b:/c:/d", ":/b"} {
+		if err = deviceSpecsSetting(c, value); err == nil {
+			t.Errorf("Expected device entry %q to be rejected", value)
+		}
+	}
+	if err = mountsSetting(c, "/a:/b:rx"); err == nil {
+		t.Error("Expected mount option rx to be rejected")
+	}
+}
+
+// Missing or invalid extra devices and mounts fail the load, strict or not.
+func TestValidateExtras(t *testing.T) {
+	logDir := t.TempDir()
+	for _, strict := range []bool{false, true} {
+		c := Defaults()
+		c.Strict = strict
+		c.DevRoot = t.TempDir()
+		c.EnclaveExtraDevices = []DeviceSpec{{HostPath: "/dev/vsock"}, {HostPath: logDir, Permissions: "rx"}}
+		c.EnclaveExtraMounts = []Mount{{HostPath: logDir}, {HostPath: "/run/missing"}, {HostPath: "relative/path"}}
+
+		err := c.Validate()
+		if err == nil || strings.Count(err.Error(), "invalid extra enclave") != 4 || !errors.Is(err, errInvalidExtra) {
+			t.Errorf("Validate() with strict %v error = %v, want 4 invalid entries", strict, err)
+		}
+		if len(c.EnclaveExtraDevices) != 2 || len(c.EnclaveExtraMounts) != 3 {
+			t.Errorf("Validate() with strict %v changed the entries to %+v and %+v", strict, c.EnclaveExtraDevices, c.EnclaveExtraMounts)
+		}
+	}
+
+	loader := NewLoader()
+	loader.ConfigFile = writeConfigFile(t, "config.yaml", `
+enclaveExtraMounts:
+  - hostPath: /run/missing
+`)
+	if _, err := loader.Load(); err == nil {
+		t.Error("Load() succeeded with a missing extra mount in a non-strict config")
+	}
+}
+
+func TestExtrasConfigFile(t *testing.T) {
+	logDir := t.TempDir()
+	loader := NewLoader()
+	loader.ConfigFile = writeConfigFile(t, "config.yaml", `
+enclaveExtraMounts:
+  - hostPath: `+logDir+`
+    containerPath: /var/log/nitro_enclaves
+    readOnly: true
+`)
+	config, err := loader.Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if want := []Mount{{HostPath: logDir, ContainerPath: "/var/log/nitro_enclaves", ReadOnly: true}}; !reflect.DeepEqual(config.EnclaveExtraMounts, want) {
+		t.Errorf("EnclaveExtraMounts = %+v, want %+v", config.EnclaveExtraMounts, want)
+	}
+}
@@ -0,0 +1,87 @@
+// Copyright 2025 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"github.com/fsnotify/fsnotify"
+	"github.com/golang/glog"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+)
+
+const (
+	// reloadDebounce coalesces the bursts of events caused by editors and ConfigMap updates.
+	reloadDebounce = 500 * time.Millisecond
+	// configMapDataDir is the symlink atomically swapped by the kubelet on ConfigMap updates.
+	configMapDataDir = "..data"
+)
+
+// Watch reloads the configuration whenever the config file changes or the process receives
+// SIGHUP, and hands every successfully loaded config to onChange. A config that fails to load
+// is reported and the previous one stays in effect. Watch blocks until stop is closed.
+func (l *Loader) Watch(stop <-chan interface{}, onChange func(*PluginConfig)) {
+	sighup := make(chan os.Signal, 1)
+	signal.Notify(sighup, syscall.SIGHUP)
+	defer signal.Stop(sighup)
+
+	var events <-chan fsnotify.Event
+	var errs <-chan error
+	if l.ConfigFile != "" {
+		watcher, err := fsnotify.NewWatcher()
+		if err != nil {
+			glog.Errorf("Unable to watch config file %s, reloading on SIGHUP only: %v", l.ConfigFile, err)
+		} else {
+			defer watcher.Close()
+			// Watch the directory rather than the file, which may be replaced, e.g. by a ConfigMap update.
+			if err = watcher.Add(filepath.Dir(l.ConfigFile)); err != nil {
+				glog.Errorf("Unable to watch config file %s, reloading on SIGHUP only: %v", l.ConfigFile, err)
+			} else {
+				events, errs = watcher.Events, watcher.Errors
+			}
+		}
+	}
+
+	debounce := time.NewTimer(reloadDebounce)
+	debounce.Stop()
+	defer debounce.Stop()
+
+	reload := func(reason string) {
+		config, err := l.Load()
+		if err != nil {
+			glog.Errorf("Error reloading plugin config after %s, keeping the current one: %v", reason, err)
+			return
+		}
+		glog.V(0).Infof("Reloaded plugin config after %s: %+v", reason, *config)
+		onChange(config)
+	}
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-sighup:
+			reload("SIGHUP")
+		case event, ok := <-events:
+			if !ok {
+				events = nil
+				continue
+			}
+			name := filepath.Base(event.Name)
+			if name == filepath.Base(l.ConfigFile) || name == configMapDataDir {
+				debounce.Reset(reloadDebounce)
+			}
+		case err, ok := <-errs:
+			if !ok {
+				errs = nil
+				continue
+			}
+			glog.Errorf("Error watching config file %s: %v", l.ConfigFile, err)
+		case <-debounce.C:
+			reload("config file change")
+		}
+	}
+}
@@ -0,0 +1,180 @@
+// Copyright 2026 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package cpulist implements the Linux cpulist format (e.g. "0-3,8,10-11"), as used in
+// /sys/devices/system/cpu/offline or the thread_siblings_list topology files, and basic
+// set operations on CPU numbers.
+package cpulist
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// MaxCPU is the highest CPU number accepted by Parse. It matches the largest NR_CPUS the
+// kernel can be built with (CONFIG_MAXSMP), and bounds the memory spent on expanding ranges.
+const MaxCPU = 8191
+
+// CPUSet is an immutable set of CPU numbers.
+type CPUSet struct {
+	elems map[int]struct{}
+}
+
+// New returns a CPUSet containing the given CPUs.
+func New(cpus ...int) CPUSet {
+	s := CPUSet{elems: make(map[int]struct{}, len(cpus))}
+	for _, cpu := range cpus {
+		s.elems[cpu] = struct{}{}
+	}
+	return s
+}
+
+// Parse parses a cpulist such as "0-3,8,10-11". Surrounding whitespace is ignored and an
+// empty list yields an empty set. Negative CPU numbers or ones above MaxCPU, reversed
+// ranges and empty elements are rejected.
+func Parse(list string) (CPUSet, error) {
+	content := strings.TrimSpace(list)
+	if content == "" {
+		return New(), nil
+	}
+
+	var cpus []int
+	for _, r := range strings.Split(content, ",") {
+		parts := strings.Split(r, "-")
+		switch len(parts) {
+		case 1:
+			cpu, err := parseCPU(parts[0])
+			if err != nil {
+				return CPUSet{}, fmt.Errorf("invalid CPU number: %q, parsing caused error: %w", r, err)
+			}
+			cpus = append(cpus, cpu)
+		case 2:
+			start, err1 := parseCPU(parts[0])
+			end, err2 := parseCPU(parts[1])
+			if err1 != nil || err2 != nil {
+				return CPUSet{}, fmt.Errorf("invalid CPU range: %q", r)
+			}
+			if start > end {
+				return CPUSet{}, fmt.Errorf("reversed CPU range: %q", r)
+			}
+			for cpu := start; cpu <= end; cpu++ {
+				cpus = append(cpus, cpu)
+			}
+		default:
+			return CPUSet{}, fmt.Errorf("malformed CPU range: %q", r)
+		}
+	}
+
+	return New(cpus...), nil
+}
+
+func parseCPU(s string) (int, error) {
+	cpu, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, err
+	}
+	if cpu < 0 {
+		return 0, fmt.Errorf("negative CPU number %d", cpu)
+	}
+	if cpu > MaxCPU {
+		return 0, fmt.Errorf("CPU number %d exceeds the maximum of %d", cpu, MaxCPU)
+	}
+	return cpu, nil
+}
+
+// Size returns the number of CPUs in the set.
+func (s CPUSet) Size() int {
+	return len(s.elems)
+}
+
+// IsEmpty reports whether the set contains no CPUs.
+func (s CPUSet) IsEmpty() bool {
+	return len(s.elems) == 0
+}
+
+// Contains reports whether the set contains the given CPU.
+func (s CPUSet) Contains(cpu int) bool {
+	_, ok := s.elems[cpu]
+	return ok
+}
+
+// List returns the CPUs of the set in ascending order.
+func (s CPUSet) List() []int {
+	cpus := make([]int, 0, len(s.elems))
+	for cpu := range s.elems {
+		cpus = append(cpus, cpu)
+	}
+	sort.Ints(cpus)
+	return cpus
+}
+
+// Equals reports whether both sets contain the same CPUs.
+func (s CPUSet) Equals(other CPUSet) bool {
+	if s.Size() != other.Size() {
+		return false
+	}
+	for cpu := range s.elems {
+		if !other.Contains(cpu) {
+			return false
+		}
+	}
+	return true
+}
+
+// IsSubsetOf reports whether every CPU of the set is also contained in other.
+func (s CPUSet) IsSubsetOf(other CPUSet) bool {
+	for cpu := range s.elems {
+		if !other.Contains(cpu) {
+			return false
+		}
+	}
+	return true
+}
+
+// Union returns the CPUs contained in either set.
+func (s CPUSet) Union(other CPUSet) CPUSet {
+	return New(append(s.List(), other.List()...)...)
+}
+
+// Intersection returns the CPUs contained in both sets.
+func (s CPUSet) Intersection(other CPUSet) CPUSet {
+	var cpus []int
+	for cpu := range s.elems {
+		if other.Contains(cpu) {
+			cpus = append(cpus, cpu)
+		}
+	}
+	return New(cpus...)
+}
+
+// Difference returns the CPUs of the set which are not contained in other.
+func (s CPUSet) Difference(other CPUSet) CPUSet {
+	var cpus []int
+	for cpu := range s.elems {
+		if !other.Contains(cpu) {
+			cpus = append(cpus, cpu)
+		}
+	}
+	return New(cpus...)
+}
+
+// String formats the set as a cpulist, collapsing consecutive CPUs into ranges.
+func (s CPUSet) String() string {
+	cpus := s.List()
+	var ranges []string
+	for i := 0; i < len(cpus); {
+		j := i
+		for j+1 < len(cpus) && cpus[j+1] == cpus[j]+1 {
+			j++
+		}
+		if i == j {
+			ranges = append(ranges, strconv.Itoa(cpus[i]))
+		} else {
+			ranges = append(ranges, strconv.Itoa(cpus[i])+"-"+strconv.Itoa(cpus[j]))
+		}
+		i = j + 1
+	}
+	return strings.Join(ranges, ",")
+}
@@ -0,0 +1,103 @@
+// Copyright 2026 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package cpulist
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    []int
+		wantErr bool
+	}{
+		{name: "empty content", input: "", want: []int{}},
+		{name: "newline only", input: "\n", want: []int{}},
+		{name: "single CPU", input: "1", want: []int{1}},
+		{name: "multiple single CPUs", input: "1,2,3,20", want: []int{1, 2, 3, 20}},
+		{name: "CPU range", input: "1-3", want: []int{1, 2, 3}},
+		{name: "CPU ranges", input: "1-3,5-7\n", want: []int{1, 2, 3, 5, 6, 7}},
+		{name: "multiple ranges", input: "1-3,5,7-8", want: []int{1, 2, 3, 5, 7, 8}},
+		{name: "overlapping ranges", input: "1-3,2-4", want: []int{1, 2, 3, 4}},
+		{name: "single CPU range", input: "4-4", want: []int{4}},
+		{name: "corrupt file", input: "1,2,", wantErr: true},
+		{name: "invalid range", input: "1-3-4", wantErr: true},
+		{name: "invalid number", input: "a-b", wantErr: true},
+		{name: "reversed range", input: "5-3", wantErr: true},
+		{name: "negative number", input: "-1", wantErr: true},
+		{name: "open range", input: "3-", wantErr: true},
+		{name: "highest CPU", input: "8190-8191", want: []int{8190, 8191}},
+		{name: "CPU beyond the maximum", input: "8192", wantErr: true},
+		{name: "huge range", input: "0-2000000000", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Parse() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got.List(), tt.want) {
+				t.Errorf("Parse() = %v, want %v", got.List(), tt.want)
+			}
+		})
+	}
+}
+
+func TestString(t *testing.T) {
+	tests := []struct {
+		cpus []int
+		want string
+	}{
+		{cpus: nil, want: ""},
+		{cpus: []int{5}, want: "5"},
+		{cpus: []int{13, 4, 12, 5}, want: "4-5,12-13"},
+		{cpus: []int{0, 1, 2, 3, 8, 10, 11}, want: "0-3,8,10-11"},
+	}
+
+	for _, tt := range tests {
+		if got := New(tt.cpus...).String(); got != tt.want {
+			t.Errorf("New(%v).String() = %q, want %q", tt.cpus, got, tt.want)
+		}
+	}
+}
+
+func TestParseStringRoundTrip(t *testing.T) {
+	for _, list := range []string{"0", "0-3,8,10-11", "1,3,5,7"} {
+		s, err := Parse(list)
+		if err != nil {
+			t.Fatalf("Parse(%q) error = %v", list, err)
+		}
+		if s.String() != list {
+			t.Errorf("Parse(%q).String() = %q", list, s.String())
+		}
+	}
+}
+
+func TestSetOperations(t *testing.T) {
+	a := New(1, 2, 3, 4)
+	b := New(3, 4, 5)
+
+	if got := a.Union(b).String(); got != "1-5" {
+		t.Errorf("Union() = %q, want %q", got, "1-5")
+	}
+	if got := a.Intersection(b).String(); got != "3-4" {
+		t.Errorf("Intersection() = %q, want %q", got, "3-4")
+	}
+	if got := a.Difference(b).String(); got != "1-2" {
+		t.Errorf("Difference() = %q, want %q", got, "1-2")
+	}
+	if !New(3, 4).IsSubsetOf(a) || b.IsSubsetOf(a) {
+		t.Error("IsSubsetOf() returned an unexpected result")
+	}
+	if !a.Equals(New(4, 3, 2, 1)) || a.Equals(b) {
+		t.Error("Equals() returned an unexpected result")
+	}
+	if !a.Contains(1) || a.Contains(5) || a.Size() != 4 || a.IsEmpty() || !New().IsEmpty() {
+		t.Error("Contains(), Size() or IsEmpty() returned an unexpected result")
+	}
+}
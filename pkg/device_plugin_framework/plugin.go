@@ -0,0 +1,463 @@
+// Copyright 2025 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package device_plugin_framework implements the parts of a Kubernetes device plugin which are
+// common to all Nitro Enclaves resources: the gRPC server lifecycle, the registration with the
+// kubelet and the distribution of device list updates to ListAndWatch streams. A resource only
+// provides its devices and its allocation logic, see Resource.
+package device_plugin_framework
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"k8s-ne-device-plugin/pkg/introspection"
+	"k8s-ne-device-plugin/pkg/metrics"
+	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+)
+
+const (
+	// ResourceNamespace prefixes the names of all Nitro Enclaves resources.
+	ResourceNamespace = "aws.ec2.nitro"
+
+	serverReadyTimeout  = 10 * time.Second
+	registrationTimeout = 10 * time.Second
+
+	// maxRecentAllocations is the number of allocations kept for introspection.
+	maxRecentAllocations = 20
+)
+
+var (
+	// ErrKubeletSocketMissing means that the kubelet is not up (yet).
+	ErrKubeletSocketMissing = errors.New("kubelet socket is missing")
+	// ErrRegistrationTimeout means that the kubelet did not answer the registration in time.
+	ErrRegistrationTimeout = errors.New("kubelet registration timed out")
+	// ErrRegistrationRejected means that the kubelet refused the registration, e.g. because
+	// of an unsupported device plugin API version.
+	ErrRegistrationRejected = errors.New("kubelet rejected the registration")
+)
+
+// Resource is the resource specific part of a device plugin.
+type Resource interface {
+	// DeviceName returns the name of the resource within ResourceNamespace, e.g. "nitro_enclaves".
+	DeviceName() string
+	// Devices returns a snapshot of the devices to advertise to the kubelet.
+	Devices() []*pluginapi.Device
+	// ContainerAllocate returns the allocation of the given devices to a single container.
+	ContainerAllocate(req *pluginapi.ContainerAllocateRequest) (*pluginapi.ContainerAllocateResponse, error)
+}
+
+// PreferredAllocator is implemented by resources with a preference on which of the available
+// devices are allocated together.
+type PreferredAllocator interface {
+	// PreferredAllocation returns the IDs of the devices preferred for a single container.
+	PreferredAllocation(req *pluginapi.ContainerPreferredAllocationRequest) ([]string, error)
+}
+
+// PoolChecker is implemented by resources which cross-check their device pool against other
+// sources, see DevicePool.
+type PoolChecker interface {
+	// PoolMismatch returns the differences last found, or nil if all sources match.
+	PoolMismatch() error
+}
+
+// Watcher is implemented by resources whose devices change at runtime.
+type Watcher interface {
+	// Watch keeps the devices up to date while the plugin server runs, reporting changes via
+	// Plugin.Update. It returns once stop is closed.
+	Watch(stop <-chan interface{})
+}
+
+// Plugin serves a Resource as Kubernetes device plugin.
+type Plugin struct {
+	resource Resource
+	// devicePluginDir holds the plugin socket and the kubelet registration socket.
+	devicePluginDir string
+
+	// mutex guards server, stop, registered, streams, lastRegistration and allocations.
+	mutex  sync.Mutex
+	server *grpc.Server
+	// stop is closed when the current server run ends, terminating its ListAndWatch streams
+	// and the resource watcher.
+	stop       chan interface{}
+	registered bool
+	streams    map[chan []*pluginapi.Device]struct{}
+	// lastRegistration and allocations are kept for introspection, see Status.
+	lastRegistration *introspection.Registration
+	allocations      []introspection.Allocation
+}
+
+// NewPlugin returns a device plugin serving resource in devicePluginDir, usually
+// pluginapi.DevicePluginPath.
+func NewPlugin(resource Resource, devicePluginDir string) *Plugin {
+	return &Plugin{
+		resource:        resource,
+		devicePluginDir: devicePluginDir,
+		streams:         make(map[chan []*pluginapi.Device]struct{}),
+	}
+}
+
+// ResourceName returns the fully qualified name of the served resource.
+func (p *Plugin) ResourceName() string {
+	return ResourceNamespace + "/" + p.resource.DeviceName()
+}
+
+// SocketPath returns the path of the unix socket the plugin is served on.
+func (p *Plugin) SocketPath() string {
+	return filepath.Join(p.devicePluginDir, p.resource.DeviceName()+".sock")
+}
+
+// kubeletSocketPath returns the path of the kubelet registration socket.
+func (p *Plugin) kubeletSocketPath() string {
+	return filepath.Join(p.devicePluginDir, filepath.Base(pluginapi.KubeletSocket))
+}
+
+// Update sends the current devices of the resource to all active ListAndWatch streams. A
+// stream that has not consumed its previous update gets it replaced by the latest one.
+func (p *Plugin) Update() {
+	devs := p.resource.Devices()
+	metrics.SetDevices(p.ResourceName(), devs)
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	for stream := range p.streams {
+		select {
+		case <-stream:
+		default:
+		}
+		stream <- devs
+	}
+}
+
+func (p *Plugin) subscribe() (chan []*pluginapi.Device, <-chan interface{}) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	stream := make(chan []*pluginapi.Device, 1)
+	p.streams[stream] = struct{}{}
+	return stream, p.stop
+}
+
+func (p *Plugin) unsubscribe(stream chan []*pluginapi.Device) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	delete(p.streams, stream)
+}
+
+// Registered reports whether the device plugin is registered with the kubelet.
+func (p *Plugin) Registered() bool {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.registered
+}
+
+// ListAndWatchStreams returns the number of active ListAndWatch streams.
+func (p *Plugin) ListAndWatchStreams() int {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return len(p.streams)
+}
+
+// recordRegistration keeps the outcome of the latest registration attempt.
+func (p *Plugin) recordRegistration(err error) {
+	registration := &introspection.Registration{Time: time.Now()}
+	if err != nil {
+		registration.Error = err.Error()
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.lastRegistration = registration
+}
+
+// recordAllocation keeps the latest maxRecentAllocations container allocations.
+func (p *Plugin) recordAllocation(deviceIDs []string, err error) {
+	allocation := introspection.Allocation{Time: time.Now(), DeviceIDs: append([]string{}, deviceIDs...)}
+	if err != nil {
+		allocation.Error = err.Error()
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.allocations = append(p.allocations, allocation)
+	if len(p.allocations) > maxRecentAllocations {
+		p.allocations = p.allocations[len(p.allocations)-maxRecentAllocations:]
+	}
+}
+
+// Status returns the devices, the pool mismatch, the kubelet registration, the active
+// ListAndWatch streams and the recent allocations of the plugin. The state is left to the
+// plugin monitor.
+func (p *Plugin) Status() introspection.PluginStatus {
+	devices := introspection.Devices(p.resource.Devices())
+	var mismatch string
+	if checker, ok := p.resource.(PoolChecker); ok && checker.PoolMismatch() != nil {
+		mismatch = checker.PoolMismatch().Error()
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	status := introspection.PluginStatus{
+		ResourceName:        p.ResourceName(),
+		Registered:          p.registered,
+		ListAndWatchStreams: len(p.streams),
+		Devices:             devices,
+		PoolMismatch:        mismatch,
+		RecentAllocations:   append([]introspection.Allocation{}, p.allocations...),
+	}
+	if p.lastRegistration != nil {
+		registration := *p.lastRegistration
+		status.LastRegistration = &registration
+	}
+	return status
+}
+
+// removeSocket deletes a socket left behind by a previous server run.
+func (p *Plugin) removeSocket() {
+	if err := os.Remove(p.SocketPath()); err != nil && !errors.Is(err, os.ErrNotExist) {
+		glog.Errorf("Error removing socket file: %s", err)
+	}
+}
+
+// closeStop ends the server run identified by stop, unless it already ended.
+func (p *Plugin) closeStop(stop chan interface{}) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.stop == stop {
+		close(stop)
+		p.stop = nil
+	}
+}
+
+// register registers the device plugin with the kubelet. Failures are classified as
+// ErrKubeletSocketMissing, ErrRegistrationTimeout or ErrRegistrationRejected where possible.
+func (p *Plugin) register(kubeletEndpoint string) error {
+	glog.V(0).Infof("Attempting %v device plugin to connect to kubelet...", p.ResourceName())
+	if _, err := os.Stat(kubeletEndpoint); err != nil {
+		return fmt.Errorf("%w: %v", ErrKubeletSocketMissing, err)
+	}
+
+	conn, err := grpc.NewClient("unix://"+kubeletEndpoint, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := conn.Close(); err != nil {
+			glog.Errorf("Error closing connection to kubelet: %s", err)
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), registrationTimeout)
+	defer cancel()
+
+	client := pluginapi.NewRegistrationClient(conn)
+	_, err = client.Register(ctx, &pluginapi.RegisterRequest{
+		Version:      pluginapi.Version,
+		Endpoint:     path.Base(p.SocketPath()),
+		ResourceName: p.ResourceName(),
+		Options:      p.options(),
+	}, grpc.WaitForReady(true))
+
+	return classifyRegistrationError(err)
+}
+
+// classifyRegistrationError wraps an error returned by the kubelet registration service into
+// ErrRegistrationTimeout or ErrRegistrationRejected.
+func classifyRegistrationError(err error) error {
+	if err == nil {
+		return nil
+	}
+	switch status.Code(err) {
+	case codes.DeadlineExceeded:
+		return fmt.Errorf("%w: %v", ErrRegistrationTimeout, err)
+	case codes.Unavailable, codes.Canceled:
+		return err
+	default:
+		return fmt.Errorf("%w: %v", ErrRegistrationRejected, status.Convert(err).Message())
+	}
+}
+
+// waitForServerReady ensures that the gRPC server of the device plugin is ready to serve.
+func waitForServerReady(server *grpc.Server, timeout time.Duration) error {
+	for deadline := time.Now().Add(timeout); time.Now().Before(deadline); time.Sleep(time.Second) {
+		if len(server.GetServiceInfo()) >= 1 {
+			return nil
+		}
+	}
+
+	return errors.New("gRPC server initialization timed out")
+}
+
+// Start serves the device plugin, starts the resource watcher and registers with the kubelet.
+func (p *Plugin) Start() error {
+	p.removeSocket()
+	glog.V(0).Infof("Starting %v device plugin server...", p.ResourceName())
+
+	sock, err := net.Listen("unix", p.SocketPath())
+	if err != nil {
+		glog.Errorf("Error while creating socket: %v", p.SocketPath())
+		return err
+	}
+
+	server := grpc.NewServer(metrics.ServerOptions(p.ResourceName())...)
+	pluginapi.RegisterDevicePluginServer(server, p)
+	stop := make(chan interface{})
+
+	p.mutex.Lock()
+	p.server = server
+	p.stop = stop
+	p.mutex.Unlock()
+
+	go func() {
+		if err := server.Serve(sock); err != nil {
+			glog.Errorf("Error while serving %v device plugin: %v", p.ResourceName(), err)
+			p.closeStop(stop)
+		}
+	}()
+	if err = waitForServerReady(server, serverReadyTimeout); err != nil {
+		p.Stop()
+		return err
+	}
+
+	if watcher, ok := p.resource.(Watcher); ok {
+		go watcher.Watch(stop)
+	}
+
+	err = p.register(p.kubeletSocketPath())
+	metrics.ObserveRegistration(p.ResourceName(), err)
+	p.recordRegistration(err)
+	if err != nil {
+		glog.Errorf("Error while registering %v device plugin with kubelet! (Reason: %s)", p.ResourceName(), err)
+		p.Stop()
+		return err
+	}
+
+	p.mutex.Lock()
+	p.registered = true
+	p.mutex.Unlock()
+	glog.V(0).Infof("Registered device plugin with Kubelet: %v", p.ResourceName())
+
+	return nil
+}
+
+// Stop ends the current server run, if any.
+func (p *Plugin) Stop() {
+	p.mutex.Lock()
+	server, stop := p.server, p.stop
+	p.server, p.stop, p.registered = nil, nil, false
+	p.mutex.Unlock()
+
+	if stop != nil {
+		close(stop)
+	}
+	if server != nil {
+		server.Stop()
+		p.removeSocket()
+		glog.V(0).Infof("%v device plugin stopped. (Socket: %s)", p.ResourceName(), p.SocketPath())
+	}
+}
+
+func (p *Plugin) options() *pluginapi.DevicePluginOptions {
+	_, preferred := p.resource.(PreferredAllocator)
+	return &pluginapi.DevicePluginOptions{
+		GetPreferredAllocationAvailable: preferred,
+	}
+}
+
+// GetDevicePluginOptions returns options to be communicated with Device Manager.
+func (p *Plugin) GetDevicePluginOptions(context.Context, *pluginapi.Empty) (*pluginapi.DevicePluginOptions, error) {
+	return p.options(), nil
+}
+
+// ListAndWatch returns a stream of List of Devices
+// Whenever a Device state change or a Device disappears, ListAndWatch
+// returns the new list
+func (p *Plugin) ListAndWatch(e *pluginapi.Empty, s pluginapi.DevicePlugin_ListAndWatchServer) error {
+	updates, stop := p.subscribe()
+	defer p.unsubscribe(updates)
+
+	err := s.Send(&pluginapi.ListAndWatchResponse{Devices: p.resource.Devices()})
+	if err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case devs := <-updates:
+			if err = s.Send(&pluginapi.ListAndWatchResponse{Devices: devs}); err != nil {
+				glog.Errorf("Error while sending %v device list update: %v", p.ResourceName(), err)
+				return err
+			}
+		case <-s.Context().Done():
+			return nil
+		case <-stop:
+			return nil
+		}
+	}
+}
+
+// GetPreferredAllocation returns a preferred set of devices to allocate
+// from a list of available ones. The resulting preferred allocation is not
+// guaranteed to be the allocation ultimately performed by the
+// devicemanager. It is only designed to help the devicemanager make a more
+// informed allocation decision when possible.
+func (p *Plugin) GetPreferredAllocation(ctx context.Context, reqs *pluginapi.PreferredAllocationRequest) (*pluginapi.PreferredAllocationResponse, error) {
+	response := &pluginapi.PreferredAllocationResponse{}
+	allocator, ok := p.resource.(PreferredAllocator)
+	if !ok {
+		return response, nil
+	}
+
+	for _, req := range reqs.ContainerRequests {
+		ids, err := allocator.PreferredAllocation(req)
+		if err != nil {
+			return nil, err
+		}
+		response.ContainerResponses = append(response.ContainerResponses, &pluginapi.ContainerPreferredAllocationResponse{
+			DeviceIDs: ids,
+		})
+	}
+
+	return response, nil
+}
+
+// Allocate is called during container creation so that the Device
+// Plugin can run device specific operations and instruct Kubelet
+// of the steps to make the Device available in the container
+func (p *Plugin) Allocate(ctx context.Context, reqs *pluginapi.AllocateRequest) (*pluginapi.AllocateResponse, error) {
+	responses := &pluginapi.AllocateResponse{}
+	for _, req := range reqs.ContainerRequests {
+		response, err := p.resource.ContainerAllocate(req)
+		p.recordAllocation(req.DevicesIDs, err)
+		if err != nil {
+			glog.Errorf("Error while allocating %v devices %v: %v", p.ResourceName(), req.DevicesIDs, err)
+			return nil, err
+		}
+		responses.ContainerResponses = append(responses.ContainerResponses, response)
+	}
+
+	return responses, nil
+}
+
+// PreStartContainer is called, if indicated by Device Plugin during registration phase,
+// before each container start. Device plugin can run device specific operations
+// such as resetting the device before making devices available to the container.
+func (p *Plugin) PreStartContainer(context.Context, *pluginapi.PreStartContainerRequest) (*pluginapi.PreStartContainerResponse, error) {
+	return &pluginapi.PreStartContainerResponse{}, nil
+}
@@ -0,0 +1,249 @@
+// Copyright 2026 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package device_plugin_framework
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+	"k8s-ne-device-plugin/pkg/fake_kubelet"
+	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+)
+
+// fakeResource advertises a fixed device list and allocates nothing but environment variables.
+type fakeResource struct {
+	mutex   sync.Mutex
+	devices []*pluginapi.Device
+}
+
+func (f *fakeResource) DeviceName() string {
+	return "fake"
+}
+
+func (f *fakeResource) Devices() []*pluginapi.Device {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return append([]*pluginapi.Device(nil), f.devices...)
+}
+
+func (f *fakeResource) setDevices(devs ...*pluginapi.Device) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.devices = devs
+}
+
+func (f *fakeResource) ContainerAllocate(req *pluginapi.ContainerAllocateRequest) (*pluginapi.ContainerAllocateResponse, error) {
+	if len(req.DevicesIDs) == 0 {
+		return nil, errors.New("no devices requested")
+	}
+	return &pluginapi.ContainerAllocateResponse{
+		Envs: map[string]string{"FAKE": req.DevicesIDs[0]},
+	}, nil
+}
+
+// fakePreferringResource prefers the last available devices.
+type fakePreferringResource struct {
+	fakeResource
+}
+
+func (f *fakePreferringResource) PreferredAllocation(req *pluginapi.ContainerPreferredAllocationRequest) ([]string, error) {
+	return req.AvailableDeviceIDs[len(req.AvailableDeviceIDs)-int(req.AllocationSize):], nil
+}
+
+func TestResourceName(t *testing.T) {
+	p := NewPlugin(&fakeResource{}, pluginapi.DevicePluginPath)
+	if name := p.ResourceName(); name != "aws.ec2.nitro/fake" {
+		t.Errorf("ResourceName() = %v, want aws.ec2.nitro/fake", name)
+	}
+	if socket := p.SocketPath(); socket != pluginapi.DevicePluginPath+"fake.sock" {
+		t.Errorf("SocketPath() = %v, want %v", socket, pluginapi.DevicePluginPath+"fake.sock")
+	}
+	if socket := p.kubeletSocketPath(); socket != pluginapi.KubeletSocket {
+		t.Errorf("kubeletSocketPath() = %v, want %v", socket, pluginapi.KubeletSocket)
+	}
+
+	custom := NewPlugin(&fakeResource{}, "/data/kubelet/device-plugins")
+	if socket := custom.SocketPath(); socket != "/data/kubelet/device-plugins/fake.sock" {
+		t.Errorf("SocketPath() = %v, want /data/kubelet/device-plugins/fake.sock", socket)
+	}
+}
+
+// The preferred allocation is only advertised and served for resources implementing PreferredAllocator.
+func TestPreferredAllocation(t *testing.T) {
+	req := &pluginapi.PreferredAllocationRequest{
+		ContainerRequests: []*pluginapi.ContainerPreferredAllocationRequest{
+			{AvailableDeviceIDs: []string{"a", "b", "c"}, AllocationSize: 2},
+		},
+	}
+
+	plain := NewPlugin(&fakeResource{}, pluginapi.DevicePluginPath)
+	if plain.options().GetPreferredAllocationAvailable {
+		t.Error("Expected GetPreferredAllocationAvailable not to be advertised")
+	}
+	resp, err := plain.GetPreferredAllocation(context.Background(), req)
+	if err != nil || len(resp.ContainerResponses) != 0 {
+		t.Errorf("GetPreferredAllocation() = %v, %v, want an empty response", resp, err)
+	}
+
+	preferring := NewPlugin(&fakePreferringResource{}, pluginapi.DevicePluginPath)
+	if !preferring.options().GetPreferredAllocationAvailable {
+		t.Error("Expected GetPreferredAllocationAvailable to be advertised")
+	}
+	resp, err = preferring.GetPreferredAllocation(context.Background(), req)
+	if err != nil {
+		t.Fatalf("GetPreferredAllocation() error = %v", err)
+	}
+	if got := resp.ContainerResponses[0].DeviceIDs; !reflect.DeepEqual(got, []string{"b", "c"}) {
+		t.Errorf("GetPreferredAllocation() = %v, want [b c]", got)
+	}
+}
+
+func TestAllocate(t *testing.T) {
+	p := NewPlugin(&fakeResource{}, pluginapi.DevicePluginPath)
+
+	resp, err := p.Allocate(context.Background(), &pluginapi.AllocateRequest{
+		ContainerRequests: []*pluginapi.ContainerAllocateRequest{
+			{DevicesIDs: []string{"a"}},
+			{DevicesIDs: []string{"b"}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Allocate() error = %v", err)
+	}
+	if len(resp.ContainerResponses) != 2 || resp.ContainerResponses[1].Envs["FAKE"] != "b" {
+		t.Errorf("Allocate() = %v, want one response per container", resp.ContainerResponses)
+	}
+
+	_, err = p.Allocate(context.Background(), &pluginapi.AllocateRequest{
+		ContainerRequests: []*pluginapi.ContainerAllocateRequest{{DevicesIDs: []string{"a"}}, {}},
+	})
+	if err == nil {
+		t.Error("Expected Allocate() to fail if a container allocation fails")
+	}
+}
+
+// Status reports the devices, the latest registration and the latest allocations, failed ones included.
+func TestStatus(t *testing.T) {
+	resource := &fakeResource{}
+	resource.setDevices(&pluginapi.Device{ID: "a", Health: pluginapi.Healthy, Topology: &pluginapi.TopologyInfo{Nodes: []*pluginapi.NUMANode{{ID: 1}}}})
+	p := NewPlugin(resource, pluginapi.DevicePluginPath)
+
+	status := p.Status()
+	if status.ResourceName != "aws.ec2.nitro/fake" || status.LastRegistration != nil || len(status.RecentAllocations) != 0 {
+		t.Errorf("Status() = %+v, want no registration and allocations", status)
+	}
+	if len(status.Devices) != 1 || status.Devices[0].ID != "a" || !reflect.DeepEqual(status.Devices[0].NUMANodes, []int64{1}) {
+		t.Errorf("Status().Devices = %+v, want device a on NUMA node 1", status.Devices)
+	}
+
+	p.recordRegistration(ErrRegistrationRejected)
+	if status = p.Status(); status.LastRegistration == nil || status.LastRegistration.Error != ErrRegistrationRejected.Error() {
+		t.Errorf("Status().LastRegistration = %+v, want %v", status.LastRegistration, ErrRegistrationRejected)
+	}
+
+	for i := 0; i < maxRecentAllocations; i++ {
+		p.Allocate(context.Background(), &pluginapi.AllocateRequest{
+			ContainerRequests: []*pluginapi.ContainerAllocateRequest{{DevicesIDs: []string{"a"}}},
+		})
+	}
+	p.Allocate(context.Background(), &pluginapi.AllocateRequest{
+		ContainerRequests: []*pluginapi.ContainerAllocateRequest{{}},
+	})
+	allocations := p.Status().RecentAllocations
+	if len(allocations) != maxRecentAllocations {
+		t.Fatalf("Status().RecentAllocations has %d entries, want %d", len(allocations), maxRecentAllocations)
+	}
+	if latest := allocations[len(allocations)-1]; latest.Error != "no devices requested" || len(latest.DeviceIDs) != 0 {
+		t.Errorf("Latest allocation = %+v, want the failed one", latest)
+	}
+}
+
+// Updates reach every active ListAndWatch stream, and Stop ends the streams without closing
+// anything twice.
+func TestUpdateAndStop(t *testing.T) {
+	resource := &fakeResource{}
+	resource.setDevices(&pluginapi.Device{ID: "a", Health: pluginapi.Healthy})
+	p := NewPlugin(resource, pluginapi.DevicePluginPath)
+	stop := make(chan interface{})
+	p.stop = stop
+
+	stream := fake_kubelet.NewListAndWatchServer(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- p.ListAndWatch(&pluginapi.Empty{}, stream)
+	}()
+
+	receive := func() []*pluginapi.Device {
+		select {
+		case devs := <-stream.Updates:
+			return devs
+		case <-time.After(5 * time.Second):
+			t.Fatal("Timed out waiting for a device list")
+			return nil
+		}
+	}
+	if devs := receive(); len(devs) != 1 {
+		t.Fatalf("Initial device list = %v, want 1 device", devs)
+	}
+
+	for p.ListAndWatchStreams() != 1 {
+		time.Sleep(10 * time.Millisecond)
+	}
+	resource.setDevices(&pluginapi.Device{ID: "a", Health: pluginapi.Healthy}, &pluginapi.Device{ID: "b", Health: pluginapi.Healthy})
+	p.Update()
+	if devs := receive(); len(devs) != 2 {
+		t.Fatalf("Updated device list = %v, want 2 devices", devs)
+	}
+
+	p.Stop()
+	p.Stop()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("ListAndWatch() error = %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("ListAndWatch did not return after Stop")
+	}
+	if p.ListAndWatchStreams() != 0 {
+		t.Errorf("ListAndWatchStreams() = %v, want 0", p.ListAndWatchStreams())
+	}
+}
+
+func TestRegisterClassifiesErrors(t *testing.T) {
+	p := NewPlugin(&fakePreferringResource{}, pluginapi.DevicePluginPath)
+
+	err := p.register(filepath.Join(t.TempDir(), "kubelet.sock"))
+	if !errors.Is(err, ErrKubeletSocketMissing) {
+		t.Errorf("register() error = %v, want %v", err, ErrKubeletSocketMissing)
+	}
+
+	kubelet := fake_kubelet.New(t.TempDir())
+	if err = kubelet.Start(); err != nil {
+		t.Fatalf("Error while starting the fake kubelet: %v", err)
+	}
+	defer kubelet.Stop()
+
+	if err = p.register(kubelet.SocketPath()); err != nil {
+		t.Fatalf("register() error = %v", err)
+	}
+	requests := kubelet.Requests()
+	if len(requests) != 1 {
+		t.Fatalf("kubelet got %d registrations, want 1", len(requests))
+	}
+	if req := requests[0]; req.ResourceName != "aws.ec2.nitro/fake" || req.Endpoint != "fake.sock" || !req.Options.GetPreferredAllocationAvailable {
+		t.Errorf("register() sent %v", req)
+	}
+
+	kubelet.Reject(errors.New("unsupported version"))
+	err = p.register(kubelet.SocketPath())
+	if !errors.Is(err, ErrRegistrationRejected) {
+		t.Errorf("register() error = %v, want %v", err, ErrRegistrationRejected)
+	}
+}
@@ -0,0 +1,130 @@
+// Copyright 2026 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package device_plugin_framework
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/golang/glog"
+	"k8s-ne-device-plugin/pkg/metrics"
+	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+)
+
+// DevicePool holds the devices of a resource whose pool changes at runtime, e.g. the enclave
+// CPU pool, and the differences last found between the sources of the pool, e.g. sysfs and
+// the allocator config. Devices are never removed: devices leaving the pool are marked
+// unhealthy, so that the kubelet can still account for pods holding them.
+type DevicePool struct {
+	// resourceName is the fully qualified name of the resource the pool is advertised as.
+	resourceName string
+	// name names the pool in log messages, e.g. "enclave CPU pool".
+	name string
+
+	// mutex guards devices, byID and mismatch.
+	mutex   sync.Mutex
+	devices []*pluginapi.Device
+	// byID maps the ID of each device ever advertised to its device.
+	byID     map[string]*pluginapi.Device
+	mismatch error
+}
+
+// NewDevicePool returns an empty device pool named name, advertised as the resource deviceName
+// within ResourceNamespace.
+func NewDevicePool(deviceName, name string) *DevicePool {
+	return &DevicePool{
+		resourceName: ResourceNamespace + "/" + deviceName,
+		name:         name,
+		byID:         make(map[string]*pluginapi.Device),
+	}
+}
+
+// CopyDevices returns a copy of devs, safe to hand out to ListAndWatch streams.
+func CopyDevices(devs []*pluginapi.Device) []*pluginapi.Device {
+	devices := make([]*pluginapi.Device, 0, len(devs))
+	for _, d := range devs {
+		devices = append(devices, &pluginapi.Device{ID: d.ID, Health: d.Health, Topology: d.Topology})
+	}
+	return devices
+}
+
+// Devices returns a snapshot of the advertised devices, safe to hand out to ListAndWatch streams.
+func (p *DevicePool) Devices() []*pluginapi.Device {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	return CopyDevices(p.devices)
+}
+
+// HasDevice reports whether the device with the given ID is advertised, healthy or not.
+func (p *DevicePool) HasDevice(id string) bool {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	_, ok := p.byID[id]
+	return ok
+}
+
+// UpdatePool rebuilds the device list from the devices currently in the pool. Devices joining
+// the pool are added (or marked healthy again), all others are marked unhealthy. Returns
+// whether the advertised device list changed.
+func (p *DevicePool) UpdatePool(pool []*pluginapi.Device) bool {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	changed := false
+	inPool := make(map[string]bool, len(pool))
+	for _, d := range pool {
+		inPool[d.ID] = true
+
+		dev, ok := p.byID[d.ID]
+		if !ok {
+			dev = &pluginapi.Device{ID: d.ID, Health: pluginapi.Healthy, Topology: d.Topology}
+			p.byID[d.ID] = dev
+			p.devices = append(p.devices, dev)
+			changed = true
+			continue
+		}
+		if dev.Health != pluginapi.Healthy {
+			dev.Health = pluginapi.Healthy
+			changed = true
+		}
+	}
+
+	for _, dev := range p.devices {
+		if !inPool[dev.ID] && dev.Health != pluginapi.Unhealthy {
+			glog.V(0).Infof("%s left the %s, marking it unhealthy", dev.ID, p.name)
+			dev.Health = pluginapi.Unhealthy
+			changed = true
+		}
+	}
+
+	return changed
+}
+
+// SetPoolMismatch records the differences found between the sources of the pool, or nil if
+// all of them match, logs changes and publishes them as metric.
+func (p *DevicePool) SetPoolMismatch(mismatch error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if fmt.Sprint(mismatch) != fmt.Sprint(p.mismatch) {
+		if mismatch != nil {
+			glog.Warningf("Mismatch of the %s: %v", p.name, mismatch)
+		} else {
+			glog.V(0).Infof("The %s matches the allocator config", p.name)
+		}
+	}
+	p.mismatch = mismatch
+	metrics.SetPoolMismatch(p.resourceName, mismatch != nil)
+}
+
+// PoolMismatch returns the differences last found between the sources of the pool, or nil if
+// all of them match.
+func (p *DevicePool) PoolMismatch() error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	return p.mismatch
+}
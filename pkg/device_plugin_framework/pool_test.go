@@ -0,0 +1,100 @@
+// Copyright 2026 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package device_plugin_framework
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+)
+
+// health returns the health of every device by ID.
+func health(devs []*pluginapi.Device) map[string]string {
+	health := map[string]string{}
+	for _, d := range devs {
+		health[d.ID] = d.Health
+	}
+	return health
+}
+
+// Devices leaving the pool are kept as unhealthy devices and become healthy again on return.
+func TestDevicePool(t *testing.T) {
+	pool := NewDevicePool("test", "test pool")
+	topology := &pluginapi.TopologyInfo{Nodes: []*pluginapi.NUMANode{{ID: 1}}}
+
+	if !pool.UpdatePool([]*pluginapi.Device{{ID: "a", Topology: topology}, {ID: "b"}}) {
+		t.Error("Expected new devices to change the pool")
+	}
+	if pool.UpdatePool([]*pluginapi.Device{{ID: "a"}, {ID: "b"}}) {
+		t.Error("Expected the same devices to leave the pool unchanged")
+	}
+
+	if !pool.UpdatePool([]*pluginapi.Device{{ID: "b"}, {ID: "c"}}) {
+		t.Error("Expected a leaving device to change the pool")
+	}
+	devs := pool.Devices()
+	want := map[string]string{"a": pluginapi.Unhealthy, "b": pluginapi.Healthy, "c": pluginapi.Healthy}
+	if got := health(devs); !reflect.DeepEqual(got, want) {
+		t.Errorf("Devices() = %v, want %v", got, want)
+	}
+	if devs[0].ID != "a" || devs[0].Topology != topology {
+		t.Errorf("Expected a to keep its position and topology, got %v", devs[0])
+	}
+
+	if !pool.HasDevice("a") || pool.HasDevice("d") {
+		t.Error("Expected HasDevice to report the advertised devices, healthy or not")
+	}
+
+	// the snapshot is not affected by later updates
+	pool.UpdatePool([]*pluginapi.Device{{ID: "a"}})
+	if devs[0].Health != pluginapi.Unhealthy {
+		t.Error("Expected the snapshot to keep the previous health")
+	}
+}
+
+func TestPoolMismatch(t *testing.T) {
+	pool := NewDevicePool("test", "test pool")
+	if err := pool.PoolMismatch(); err != nil {
+		t.Errorf("PoolMismatch() = %v, want nil", err)
+	}
+
+	mismatch := errors.New("allocator config differs")
+	pool.SetPoolMismatch(mismatch)
+	if err := pool.PoolMismatch(); err != mismatch {
+		t.Errorf("PoolMismatch() = %v, want %v", err, mismatch)
+	}
+	pool.SetPoolMismatch(nil)
+	if err := pool.PoolMismatch(); err != nil {
+		t.Errorf("PoolMismatch() = %v, want nil", err)
+	}
+}
+
+// fakePoolResource advertises the devices of its pool.
+type fakePoolResource struct {
+	*DevicePool
+}
+
+func (f *fakePoolResource) DeviceName() string {
+	return "fake"
+}
+
+func (f *fakePoolResource) ContainerAllocate(*pluginapi.ContainerAllocateRequest) (*pluginapi.ContainerAllocateResponse, error) {
+	return &pluginapi.ContainerAllocateResponse{}, nil
+}
+
+// The pool mismatch of a resource is part of its status.
+func TestStatusPoolMismatch(t *testing.T) {
+	resource := &fakePoolResource{NewDevicePool("fake", "fake pool")}
+	p := NewPlugin(resource, t.TempDir())
+	if status := p.Status(); status.PoolMismatch != "" {
+		t.Errorf("Status().PoolMismatch = %q, want none", status.PoolMismatch)
+	}
+
+	resource.SetPoolMismatch(errors.New("allocator config differs"))
+	if status := p.Status(); status.PoolMismatch != "allocator config differs" {
+		t.Errorf("Status().PoolMismatch = %q, want the mismatch", status.PoolMismatch)
+	}
+}
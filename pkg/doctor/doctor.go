@@ -0,0 +1,96 @@
+// Copyright 2026 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package doctor collects the results of the node preflight checks of the device plugins
+// and reports them as text or JSON.
+package doctor
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Status is the outcome of a single check.
+type Status string
+
+const (
+	// Pass means that the node is ready in the checked regard.
+	Pass Status = "pass"
+	// Warn means that the node works, but likely not as intended.
+	Warn Status = "warn"
+	// Fail means that enclave pods can't run on the node.
+	Fail Status = "fail"
+)
+
+// severity orders the statuses from the best to the worst.
+var severity = map[Status]int{Pass: 0, Warn: 1, Fail: 2}
+
+// Result is the outcome of a single check.
+type Result struct {
+	Check   string `json:"check"`
+	Status  Status `json:"status"`
+	Message string `json:"message"`
+}
+
+// Passed returns a passed result of check.
+func Passed(check, format string, args ...interface{}) Result {
+	return Result{Check: check, Status: Pass, Message: fmt.Sprintf(format, args...)}
+}
+
+// Warned returns a warning result of check.
+func Warned(check, format string, args ...interface{}) Result {
+	return Result{Check: check, Status: Warn, Message: fmt.Sprintf(format, args...)}
+}
+
+// Failed returns a failed result of check.
+func Failed(check, format string, args ...interface{}) Result {
+	return Result{Check: check, Status: Fail, Message: fmt.Sprintf(format, args...)}
+}
+
+// Report is the outcome of all checks.
+type Report struct {
+	// Status is the worst status of all results.
+	Status  Status   `json:"status"`
+	Results []Result `json:"results"`
+}
+
+// NewReport collects the given results in order.
+func NewReport(results ...[]Result) *Report {
+	r := &Report{Status: Pass, Results: []Result{}}
+	for _, rs := range results {
+		for _, result := range rs {
+			r.Results = append(r.Results, result)
+			if severity[result.Status] > severity[r.Status] {
+				r.Status = result.Status
+			}
+		}
+	}
+	return r
+}
+
+// Failed reports whether any check failed.
+func (r *Report) Failed() bool {
+	return r.Status == Fail
+}
+
+// WriteText writes one line per result and a summary.
+func (r *Report) WriteText(w io.Writer) error {
+	counts := map[Status]int{}
+	for _, result := range r.Results {
+		counts[result.Status]++
+		if _, err := fmt.Fprintf(w, "[%-4s] %-20s %s\n", strings.ToUpper(string(result.Status)), result.Check, result.Message); err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprintf(w, "\n%d passed, %d warnings, %d failed\n", counts[Pass], counts[Warn], counts[Fail])
+	return err
+}
+
+// WriteJSON writes the report as an indented JSON document.
+func (r *Report) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(r)
+}
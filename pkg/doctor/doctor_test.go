@@ -0,0 +1,40 @@
+// Copyright 2026 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package doctor
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// The report status is the worst status of its results.
+func TestReport(t *testing.T) {
+	report := NewReport(
+		[]Result{Passed("first", "ok")},
+		[]Result{Warned("second", "%d stale", 2)},
+	)
+	if report.Status != Warn || report.Failed() {
+		t.Errorf("Status = %v, want %v", report.Status, Warn)
+	}
+
+	report = NewReport(report.Results, []Result{Failed("third", "missing")})
+	if report.Status != Fail || !report.Failed() {
+		t.Errorf("Status = %v, want %v", report.Status, Fail)
+	}
+
+	var out bytes.Buffer
+	if err := report.WriteText(&out); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"[PASS] first", "[WARN] second               2 stale", "[FAIL] third", "1 passed, 1 warnings, 1 failed"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("WriteText() = %q, want it to contain %q", out.String(), want)
+		}
+	}
+
+	if empty := NewReport(); empty.Status != Pass || empty.Results == nil {
+		t.Errorf("NewReport() = %+v, want a passed report", empty)
+	}
+}
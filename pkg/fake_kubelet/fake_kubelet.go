@@ -0,0 +1,259 @@
+// Copyright 2026 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package fake_kubelet implements the kubelet side of the device plugin API for end-to-end
+// tests: it serves registration on a kubelet socket in a temporary device plugin directory,
+// records the registrations and talks to the registered plugins like the kubelet does.
+package fake_kubelet
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+)
+
+// ErrStreamEnded is returned by DeviceStream once the ListAndWatch stream has ended.
+var ErrStreamEnded = errors.New("ListAndWatch stream ended")
+
+// Kubelet is a fake kubelet serving the Registration service on kubelet.sock in its device
+// plugin directory.
+type Kubelet struct {
+	pluginapi.UnimplementedRegistrationServer
+
+	dir string
+
+	// mutex guards server, requests, registered and rejectErr.
+	mutex    sync.Mutex
+	server   *grpc.Server
+	requests []*pluginapi.RegisterRequest
+	// registered is closed and replaced on every registration.
+	registered chan struct{}
+	rejectErr  error
+}
+
+// New creates a fake kubelet for the device plugin directory dir. The directory must exist
+// and, due to the length limit of unix socket paths, should be short, e.g. a t.TempDir().
+func New(dir string) *Kubelet {
+	return &Kubelet{
+		dir:        dir,
+		registered: make(chan struct{}),
+	}
+}
+
+// Dir returns the device plugin directory.
+func (k *Kubelet) Dir() string {
+	return k.dir
+}
+
+// SocketPath returns the path of the kubelet registration socket.
+func (k *Kubelet) SocketPath() string {
+	return filepath.Join(k.dir, filepath.Base(pluginapi.KubeletSocket))
+}
+
+// Start creates the kubelet socket and serves registration on it. The registrations recorded
+// so far are discarded, just like a restarted kubelet forgets its plugins.
+func (k *Kubelet) Start() error {
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+
+	if k.server != nil {
+		return errors.New("fake kubelet is already running")
+	}
+	listener, err := net.Listen("unix", k.SocketPath())
+	if err != nil {
+		return fmt.Errorf("error while creating kubelet socket: %w", err)
+	}
+
+	k.server = grpc.NewServer()
+	pluginapi.RegisterRegistrationServer(k.server, k)
+	k.requests = nil
+	go k.server.Serve(listener)
+	return nil
+}
+
+// Stop stops serving registration and removes the kubelet socket.
+func (k *Kubelet) Stop() {
+	k.mutex.Lock()
+	server := k.server
+	k.server = nil
+	k.mutex.Unlock()
+
+	if server != nil {
+		server.Stop()
+	}
+	os.Remove(k.SocketPath())
+}
+
+// Restart simulates a kubelet restart: the kubelet stops, wipes the device plugin directory,
+// including the plugin sockets, and re-creates its socket.
+func (k *Kubelet) Restart() error {
+	k.Stop()
+
+	entries, err := os.ReadDir(k.dir)
+	if err != nil {
+		return fmt.Errorf("error while reading %s: %w", k.dir, err)
+	}
+	for _, entry := range entries {
+		if err = os.RemoveAll(filepath.Join(k.dir, entry.Name())); err != nil {
+			return fmt.Errorf("error while wiping %s: %w", k.dir, err)
+		}
+	}
+
+	return k.Start()
+}
+
+// Reject makes every following registration fail with err. A nil err accepts registrations
+// again.
+func (k *Kubelet) Reject(err error) {
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+	k.rejectErr = err
+}
+
+// Register implements the kubelet Registration service. Like the kubelet, it rejects plugins
+// of an unsupported API version.
+func (k *Kubelet) Register(ctx context.Context, req *pluginapi.RegisterRequest) (*pluginapi.Empty, error) {
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+
+	if req.Version != pluginapi.Version {
+		return nil, fmt.Errorf("unsupported device plugin API version %q", req.Version)
+	}
+	if k.rejectErr != nil {
+		return nil, k.rejectErr
+	}
+
+	k.requests = append(k.requests, req)
+	close(k.registered)
+	k.registered = make(chan struct{})
+	return &pluginapi.Empty{}, nil
+}
+
+// Requests returns the registrations accepted since the last start.
+func (k *Kubelet) Requests() []*pluginapi.RegisterRequest {
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+	return append([]*pluginapi.RegisterRequest(nil), k.requests...)
+}
+
+// WaitForRegistration waits up to timeout for the registration of resourceName since the
+// last start and returns the latest one.
+func (k *Kubelet) WaitForRegistration(resourceName string, timeout time.Duration) (*pluginapi.RegisterRequest, error) {
+	deadline := time.After(timeout)
+	for {
+		k.mutex.Lock()
+		registered := k.registered
+		var found *pluginapi.RegisterRequest
+		for _, req := range k.requests {
+			if req.ResourceName == resourceName {
+				found = req
+			}
+		}
+		k.mutex.Unlock()
+
+		if found != nil {
+			return found, nil
+		}
+		select {
+		case <-registered:
+		case <-deadline:
+			return nil, fmt.Errorf("%s did not register within %v", resourceName, timeout)
+		}
+	}
+}
+
+// PluginClient is a connection to the device plugin endpoint of a registration.
+type PluginClient struct {
+	pluginapi.DevicePluginClient
+	conn *grpc.ClientConn
+}
+
+// Dial connects to the endpoint of a registered plugin, which is relative to the device
+// plugin directory.
+func (k *Kubelet) Dial(req *pluginapi.RegisterRequest) (*PluginClient, error) {
+	conn, err := grpc.NewClient("unix://"+filepath.Join(k.dir, req.Endpoint),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithDefaultCallOptions(grpc.WaitForReady(true)))
+	if err != nil {
+		return nil, fmt.Errorf("error while connecting to %s: %w", req.Endpoint, err)
+	}
+	return &PluginClient{DevicePluginClient: pluginapi.NewDevicePluginClient(conn), conn: conn}, nil
+}
+
+// Close closes the connection to the plugin.
+func (c *PluginClient) Close() error {
+	return c.conn.Close()
+}
+
+// DeviceStream receives the device lists of a ListAndWatch stream.
+type DeviceStream struct {
+	updates chan []*pluginapi.Device
+	// err is the error which ended the stream, set before updates is closed.
+	err error
+}
+
+// Watch opens a ListAndWatch stream which lives until ctx is cancelled or the plugin ends it.
+func (c *PluginClient) Watch(ctx context.Context) (*DeviceStream, error) {
+	stream, err := c.ListAndWatch(ctx, &pluginapi.Empty{})
+	if err != nil {
+		return nil, err
+	}
+
+	ds := &DeviceStream{
+		updates: make(chan []*pluginapi.Device, 16),
+	}
+	go func() {
+		for {
+			resp, err := stream.Recv()
+			if err != nil {
+				ds.err = err
+				close(ds.updates)
+				return
+			}
+			select {
+			case ds.updates <- resp.Devices:
+			case <-ctx.Done():
+				ds.err = ctx.Err()
+				close(ds.updates)
+				return
+			}
+		}
+	}()
+	return ds, nil
+}
+
+// Next waits up to timeout for the next device list. It fails once the stream has ended.
+func (ds *DeviceStream) Next(timeout time.Duration) ([]*pluginapi.Device, error) {
+	select {
+	case devs, ok := <-ds.updates:
+		if !ok {
+			return nil, fmt.Errorf("%w: %w", ErrStreamEnded, ds.err)
+		}
+		return devs, nil
+	case <-time.After(timeout):
+		return nil, fmt.Errorf("no device list within %v", timeout)
+	}
+}
+
+// NextMatching skips device lists until match accepts one, waiting up to timeout in total.
+func (ds *DeviceStream) NextMatching(match func([]*pluginapi.Device) bool, timeout time.Duration) ([]*pluginapi.Device, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		devs, err := ds.Next(time.Until(deadline))
+		if err != nil {
+			return nil, err
+		}
+		if match(devs) {
+			return devs, nil
+		}
+	}
+}
@@ -0,0 +1,40 @@
+// Copyright 2026 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package fake_kubelet
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+)
+
+// ListAndWatchServer is a ListAndWatch stream for calling a plugin's ListAndWatch directly,
+// without a gRPC connection. It records every device list sent through it.
+type ListAndWatchServer struct {
+	grpc.ServerStream
+	ctx context.Context
+	// Updates receives every device list sent through the stream.
+	Updates chan []*pluginapi.Device
+}
+
+// NewListAndWatchServer returns a stream which ends once ctx is cancelled. Up to 4 device
+// lists are buffered, further sends block until Updates is read.
+func NewListAndWatchServer(ctx context.Context) *ListAndWatchServer {
+	return &ListAndWatchServer{
+		ctx:     ctx,
+		Updates: make(chan []*pluginapi.Device, 4),
+	}
+}
+
+// Send records the device list of resp.
+func (s *ListAndWatchServer) Send(resp *pluginapi.ListAndWatchResponse) error {
+	s.Updates <- resp.Devices
+	return nil
+}
+
+// Context returns the context of the stream.
+func (s *ListAndWatchServer) Context() context.Context {
+	return s.ctx
+}
@@ -0,0 +1,73 @@
+// Copyright 2025 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package health serves the liveness and readiness probes of the device plugins.
+package health
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/golang/glog"
+	"k8s-ne-device-plugin/pkg/http_endpoint"
+)
+
+const (
+	// LivenessPath is the HTTP path of the liveness probe.
+	LivenessPath = "/healthz"
+	// ReadinessPath is the HTTP path of the readiness probe.
+	ReadinessPath = "/readyz"
+)
+
+// Checker reports the health of a single device plugin, e.g. its plugin monitor.
+type Checker interface {
+	ResourceName() string
+	// Live returns an error if the plugin is stuck and needs a restart.
+	Live() error
+	// Ready returns an error if the plugin is not serving the kubelet.
+	Ready() error
+}
+
+// probeHandler responds with 200 if check succeeds for all checkers and with 503 otherwise,
+// listing the result per resource.
+func probeHandler(checkers []Checker, check func(Checker) error) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var report strings.Builder
+		failed := false
+		for _, c := range checkers {
+			if err := check(c); err != nil {
+				failed = true
+				fmt.Fprintf(&report, "%s: %v\n", c.ResourceName(), err)
+			} else {
+				fmt.Fprintf(&report, "%s: ok\n", c.ResourceName())
+			}
+		}
+
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		if failed {
+			glog.V(1).Infof("%s probe failed:\n%s", r.URL.Path, report.String())
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		fmt.Fprint(w, report.String())
+	}
+}
+
+// Handler returns the handler serving the liveness and readiness probes of checkers.
+func Handler(checkers ...Checker) http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle(LivenessPath, probeHandler(checkers, Checker.Live))
+	mux.Handle(ReadinessPath, probeHandler(checkers, Checker.Ready))
+	return mux
+}
+
+// Serve exposes the probes of checkers on address in the background, see http_endpoint.Serve.
+func Serve(address string, checkers ...Checker) (*http.Server, error) {
+	server, err := http_endpoint.Serve("Health probe", address, Handler(checkers...))
+	if err != nil {
+		return nil, err
+	}
+	glog.V(0).Infof("Serving health probes on http://%s%s and %s", server.Addr, LivenessPath, ReadinessPath)
+
+	return server, nil
+}
@@ -0,0 +1,70 @@
+// Copyright 2025 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package health
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+type fakeChecker struct {
+	name  string
+	live  error
+	ready error
+}
+
+func (f *fakeChecker) ResourceName() string { return f.name }
+func (f *fakeChecker) Live() error          { return f.live }
+func (f *fakeChecker) Ready() error         { return f.ready }
+
+func probe(t *testing.T, address, path string) (int, string) {
+	t.Helper()
+	resp, err := http.Get("http://" + address + path)
+	if err != nil {
+		t.Fatalf("Error probing %s: %v", path, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("Error reading %s response: %v", path, err)
+	}
+	return resp.StatusCode, string(body)
+}
+
+func TestProbes(t *testing.T) {
+	enclaves := &fakeChecker{name: "aws.ec2.nitro/nitro_enclaves"}
+	cpus := &fakeChecker{name: "aws.ec2.nitro/nitro_enclaves_cpus", ready: errors.New("plugin is Idle")}
+
+	server, err := Serve("127.0.0.1:0", enclaves, cpus)
+	if err != nil {
+		t.Fatalf("Error starting probe endpoint: %v", err)
+	}
+	defer server.Close()
+
+	if code, _ := probe(t, server.Addr, LivenessPath); code != http.StatusOK {
+		t.Errorf("Expected %s to succeed but got %d", LivenessPath, code)
+	}
+
+	code, body := probe(t, server.Addr, ReadinessPath)
+	if code != http.StatusServiceUnavailable {
+		t.Errorf("Expected %s to fail while a plugin is not ready but got %d", ReadinessPath, code)
+	}
+	if !strings.Contains(body, "aws.ec2.nitro/nitro_enclaves_cpus: plugin is Idle") {
+		t.Errorf("Expected the failing plugin in the %s response, got:\n%s", ReadinessPath, body)
+	}
+
+	cpus.ready = nil
+	if code, _ := probe(t, server.Addr, ReadinessPath); code != http.StatusOK {
+		t.Errorf("Expected %s to succeed once all plugins are ready but got %d", ReadinessPath, code)
+	}
+
+	enclaves.live = errors.New("plugin monitor has terminated")
+	if code, _ := probe(t, server.Addr, LivenessPath); code != http.StatusServiceUnavailable {
+		t.Errorf("Expected %s to fail once a monitor terminated but got %d", LivenessPath, code)
+	}
+}
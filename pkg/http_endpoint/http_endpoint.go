@@ -0,0 +1,42 @@
+// Copyright 2026 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package http_endpoint serves the HTTP endpoints of the plugin process, e.g. the health probes
+// and the metrics, next to the gRPC device plugin servers.
+package http_endpoint
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+const readHeaderTimeout = 5 * time.Second
+
+// Serve listens on address and serves handler in the background. A failure after the start
+// is logged as failure of the endpoint called name, e.g. "Metrics". The returned server can
+// be used to shut the endpoint down, its Addr holds the address actually listened on, e.g.
+// for an address with port 0.
+func Serve(name, address string, handler http.Handler) (*http.Server, error) {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, err
+	}
+
+	server := &http.Server{
+		Addr:              listener.Addr().String(),
+		Handler:           handler,
+		ReadHeaderTimeout: readHeaderTimeout,
+	}
+
+	go func() {
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			glog.Errorf("%s endpoint on %s failed: %v", name, server.Addr, err)
+		}
+	}()
+
+	return server, nil
+}
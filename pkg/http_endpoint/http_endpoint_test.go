@@ -0,0 +1,40 @@
+// Copyright 2026 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package http_endpoint
+
+import (
+	"io"
+	"net/http"
+	"testing"
+)
+
+func TestServe(t *testing.T) {
+	server, err := Serve("Test", "127.0.0.1:0", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok")
+	}))
+	if err != nil {
+		t.Fatalf("Serve() error = %v", err)
+	}
+
+	resp, err := http.Get("http://" + server.Addr)
+	if err != nil {
+		t.Fatalf("Error querying the endpoint: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "ok" {
+		t.Errorf("Endpoint responded with %q, want ok", body)
+	}
+
+	// the address is in use until the server is closed
+	if _, err = Serve("Test", server.Addr, http.NotFoundHandler()); err == nil {
+		t.Error("Expected Serve() to fail on an address in use")
+	}
+	if err = server.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if _, err = http.Get("http://" + server.Addr); err == nil {
+		t.Error("Expected the endpoint to be shut down")
+	}
+}
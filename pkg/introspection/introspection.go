@@ -0,0 +1,220 @@
+// Copyright 2026 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package introspection serves a read-only view of the running device plugins: the devices
+// they advertise, their monitor state, the kubelet registration and the recent allocations.
+package introspection
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/golang/glog"
+	"k8s-ne-device-plugin/pkg/http_endpoint"
+	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+)
+
+const (
+	// StatusPath is the HTTP path of the plugin status.
+	StatusPath = "/status"
+
+	fetchTimeout = 5 * time.Second
+)
+
+// Device is an advertised device with its health.
+type Device struct {
+	ID     string `json:"id"`
+	Health string `json:"health"`
+	// NUMANodes lists the NUMA nodes of the device, if any.
+	NUMANodes []int64 `json:"numaNodes,omitempty"`
+}
+
+// Registration is the outcome of an attempt to register with the kubelet.
+type Registration struct {
+	Time  time.Time `json:"time"`
+	Error string    `json:"error,omitempty"`
+}
+
+// Allocation is a container allocation requested by the kubelet.
+type Allocation struct {
+	Time      time.Time `json:"time"`
+	DeviceIDs []string  `json:"deviceIDs"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// PluginStatus is a snapshot of a single device plugin.
+type PluginStatus struct {
+	ResourceName string `json:"resourceName"`
+	// State is the plugin monitor state, e.g. "Running".
+	State          string `json:"state,omitempty"`
+	LastStartError string `json:"lastStartError,omitempty"`
+	Registered     bool   `json:"registered"`
+	// LastRegistration is nil until the plugin attempted to register.
+	LastRegistration    *Registration `json:"lastRegistration,omitempty"`
+	ListAndWatchStreams int           `json:"listAndWatchStreams"`
+	Devices             []Device      `json:"devices"`
+	// PoolMismatch describes the differences last found between the sources of the device
+	// pool, e.g. sysfs and the allocator config, if any.
+	PoolMismatch string `json:"poolMismatch,omitempty"`
+	// RecentAllocations holds the latest allocations, oldest first.
+	RecentAllocations []Allocation `json:"recentAllocations"`
+}
+
+// Status is the status of all device plugins of the process.
+type Status struct {
+	Plugins []PluginStatus `json:"plugins"`
+}
+
+// Reporter reports the status of a single device plugin, e.g. its plugin monitor.
+type Reporter interface {
+	Status() PluginStatus
+}
+
+// Devices converts devices advertised to the kubelet.
+func Devices(devs []*pluginapi.Device) []Device {
+	devices := make([]Device, 0, len(devs))
+	for _, dev := range devs {
+		device := Device{ID: dev.ID, Health: dev.Health}
+		for _, node := range dev.GetTopology().GetNodes() {
+			device.NUMANodes = append(device.NUMANodes, node.ID)
+		}
+		devices = append(devices, device)
+	}
+	return devices
+}
+
+// Handler returns the handler serving the status of reporters as JSON.
+func Handler(reporters ...Reporter) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET "+StatusPath, func(w http.ResponseWriter, r *http.Request) {
+		status := Status{Plugins: make([]PluginStatus, 0, len(reporters))}
+		for _, reporter := range reporters {
+			status.Plugins = append(status.Plugins, reporter.Status())
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := status.WriteJSON(w); err != nil {
+			glog.Errorf("Error while writing the plugin status: %v", err)
+		}
+	})
+	return mux
+}
+
+// Serve exposes the status of reporters on address in the background, see http_endpoint.Serve.
+func Serve(address string, reporters ...Reporter) (*http.Server, error) {
+	server, err := http_endpoint.Serve("Status", address, Handler(reporters...))
+	if err != nil {
+		return nil, err
+	}
+	glog.V(0).Infof("Serving the plugin status on http://%s%s", server.Addr, StatusPath)
+
+	return server, nil
+}
+
+// Fetch queries the status endpoint on address. An address without host, e.g. ":8083",
+// refers to the local host.
+func Fetch(address string) (*Status, error) {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, fmt.Errorf("invalid status address %q: %w", address, err)
+	}
+	if host == "" {
+		host = "127.0.0.1"
+	}
+
+	client := &http.Client{Timeout: fetchTimeout}
+	resp, err := client.Get("http://" + net.JoinHostPort(host, port) + StatusPath)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("status endpoint responded with %s", resp.Status)
+	}
+	status := &Status{}
+	if err = json.NewDecoder(resp.Body).Decode(status); err != nil {
+		return nil, fmt.Errorf("invalid status response: %w", err)
+	}
+	return status, nil
+}
+
+// WriteJSON writes the status as indented JSON.
+func (s *Status) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(s)
+}
+
+// formatTime formats t in UTC, or "-" if t is not set.
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+// orDash returns value, or "-" if value is empty.
+func orDash(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
+
+// WriteTable writes the status of every plugin followed by tables of its devices and recent
+// allocations.
+func (s *Status) WriteTable(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	for i, plugin := range s.Plugins {
+		if i > 0 {
+			fmt.Fprintln(tw)
+		}
+		fmt.Fprintf(tw, "%s\n", plugin.ResourceName)
+		fmt.Fprintf(tw, "  State:\t%s\n", orDash(plugin.State))
+		if plugin.LastStartError != "" {
+			fmt.Fprintf(tw, "  Last start error:\t%s\n", plugin.LastStartError)
+		}
+		fmt.Fprintf(tw, "  Registered:\t%t\n", plugin.Registered)
+		switch {
+		case plugin.LastRegistration == nil:
+			fmt.Fprintf(tw, "  Last registration:\t-\n")
+		case plugin.LastRegistration.Error != "":
+			fmt.Fprintf(tw, "  Last registration:\tfailed at %s: %s\n", formatTime(plugin.LastRegistration.Time), plugin.LastRegistration.Error)
+		default:
+			fmt.Fprintf(tw, "  Last registration:\tsucceeded at %s\n", formatTime(plugin.LastRegistration.Time))
+		}
+		fmt.Fprintf(tw, "  ListAndWatch streams:\t%d\n", plugin.ListAndWatchStreams)
+
+		if plugin.PoolMismatch != "" {
+			fmt.Fprintf(tw, "  Pool mismatch:\t%s\n", plugin.PoolMismatch)
+		}
+		fmt.Fprintf(tw, "  Devices:\t%d\n", len(plugin.Devices))
+		if len(plugin.Devices) > 0 {
+			fmt.Fprintf(tw, "    ID\tHEALTH\tNUMA\n")
+			for _, device := range plugin.Devices {
+				nodes := make([]string, 0, len(device.NUMANodes))
+				for _, node := range device.NUMANodes {
+					nodes = append(nodes, strconv.FormatInt(node, 10))
+				}
+				fmt.Fprintf(tw, "    %s\t%s\t%s\n", device.ID, device.Health, orDash(strings.Join(nodes, ",")))
+			}
+		}
+
+		fmt.Fprintf(tw, "  Recent allocations:\t%d\n", len(plugin.RecentAllocations))
+		if len(plugin.RecentAllocations) > 0 {
+			fmt.Fprintf(tw, "    TIME\tDEVICES\tERROR\n")
+			for _, allocation := range plugin.RecentAllocations {
+				fmt.Fprintf(tw, "    %s\t%s\t%s\n", formatTime(allocation.Time), orDash(strings.Join(allocation.DeviceIDs, ",")), orDash(allocation.Error))
+			}
+		}
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,81 @@
+// Copyright 2026 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package introspection
+
+import (
+	"bytes"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type fakeReporter struct {
+	status PluginStatus
+}
+
+func (f *fakeReporter) Status() PluginStatus { return f.status }
+
+func TestServeAndFetch(t *testing.T) {
+	allocated := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	enclaves := &fakeReporter{status: PluginStatus{
+		ResourceName:        "aws.ec2.nitro/nitro_enclaves",
+		State:               "Running",
+		Registered:          true,
+		LastRegistration:    &Registration{Time: allocated.Add(-time.Hour)},
+		ListAndWatchStreams: 1,
+		Devices:             []Device{{ID: "nitro_enclaves_0", Health: "Healthy"}},
+		RecentAllocations:   []Allocation{{Time: allocated, DeviceIDs: []string{"nitro_enclaves_0"}}},
+	}}
+	cpus := &fakeReporter{status: PluginStatus{
+		ResourceName:      "aws.ec2.nitro/nitro_enclaves_cpus",
+		State:             "Rejected",
+		LastRegistration:  &Registration{Time: allocated, Error: "kubelet rejected the registration"},
+		Devices:           []Device{{ID: "cpu_3", Health: "Unhealthy", NUMANodes: []int64{0}}},
+		PoolMismatch:      `nitro_enclaves ne_cpus "3" are unexpectedly online and are not advertised`,
+		RecentAllocations: []Allocation{},
+	}}
+
+	server, err := Serve("127.0.0.1:0", enclaves, cpus)
+	if err != nil {
+		t.Fatalf("Error starting status endpoint: %v", err)
+	}
+	defer server.Close()
+
+	// an address without host refers to the local host
+	_, port, _ := net.SplitHostPort(server.Addr)
+	status, err := Fetch(":" + port)
+	if err != nil {
+		t.Fatalf("Fetch() error = %v", err)
+	}
+	want := []PluginStatus{enclaves.status, cpus.status}
+	if !reflect.DeepEqual(status.Plugins, want) {
+		t.Errorf("Fetch() = %+v, want %+v", status.Plugins, want)
+	}
+
+	var out bytes.Buffer
+	if err = status.WriteTable(&out); err != nil {
+		t.Fatal(err)
+	}
+	// columns are aligned with a varying number of spaces
+	table := strings.Join(strings.Fields(out.String()), " ")
+	for _, want := range []string{
+		"aws.ec2.nitro/nitro_enclaves State: Running Registered: true",
+		"Last registration: succeeded at 2026-10-18T11:00:00Z",
+		"Last registration: failed at 2026-10-18T12:00:00Z: kubelet rejected the registration",
+		"nitro_enclaves_0 Healthy -",
+		"2026-10-18T12:00:00Z nitro_enclaves_0 -",
+		`Pool mismatch: nitro_enclaves ne_cpus "3" are unexpectedly online and are not advertised Devices: 1`,
+		"cpu_3 Unhealthy 0 Recent allocations: 0",
+	} {
+		if !strings.Contains(table, want) {
+			t.Errorf("WriteTable() = \n%s\nwant it to contain %q", out.String(), want)
+		}
+	}
+
+	if _, err = Fetch("localhost"); err == nil {
+		t.Error("Expected Fetch() to fail on an address without port")
+	}
+}
@@ -0,0 +1,198 @@
+// Copyright 2025 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package metrics exports the Prometheus metrics of the Nitro Enclaves device plugins.
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"path"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"google.golang.org/grpc"
+	"k8s-ne-device-plugin/pkg/http_endpoint"
+	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+)
+
+const (
+	namespace = "nitro_enclaves_device_plugin"
+
+	// MetricsPath is the HTTP path metrics are served on.
+	MetricsPath = "/metrics"
+)
+
+var (
+	// Registry holds all metrics of the device plugins. A dedicated registry keeps the
+	// output free of the default Go runtime collectors registered by other libraries.
+	Registry = prometheus.NewRegistry()
+
+	buildInfo = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "build_info",
+		Help:      "Version and build date of the device plugin, always 1.",
+	}, []string{"version", "build_date"})
+
+	devices = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "devices",
+		Help:      "Number of devices advertised to the kubelet.",
+	}, []string{"resource"})
+
+	healthyDevices = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "healthy_devices",
+		Help:      "Number of healthy devices advertised to the kubelet.",
+	}, []string{"resource"})
+
+	calls = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "calls_total",
+		Help:      "Number of device plugin API calls made by the kubelet.",
+	}, []string{"resource", "method"})
+
+	callErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "call_errors_total",
+		Help:      "Number of device plugin API calls which returned an error.",
+	}, []string{"resource", "method"})
+
+	callDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "call_duration_seconds",
+		Help:      "Duration of device plugin API calls. ListAndWatch lasts as long as its stream.",
+		Buckets:   prometheus.ExponentialBuckets(0.0005, 4, 12),
+	}, []string{"resource", "method"})
+
+	registrations = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "registrations_total",
+		Help:      "Number of attempts to register with the kubelet.",
+	}, []string{"resource"})
+
+	registrationFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "registration_failures_total",
+		Help:      "Number of failed attempts to register with the kubelet.",
+	}, []string{"resource"})
+
+	pluginState = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "state",
+		Help:      "State of the plugin monitor: 0 = idle, 1 = running, 2 = restarting, 3 = waiting for the kubelet, 4 = rejected by the kubelet.",
+	}, []string{"resource"})
+
+	poolMismatch = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "pool_mismatch",
+		Help:      "Whether the sources of the device pool, e.g. sysfs and the allocator config, differ (1) or not (0).",
+	}, []string{"resource"})
+
+	restarts = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "restarts_total",
+		Help:      "Number of plugin restarts triggered by the kubelet socket being re-created or the plugin socket being lost.",
+	}, []string{"resource"})
+)
+
+func init() {
+	Registry.MustRegister(buildInfo, devices, healthyDevices, calls, callErrors, callDuration,
+		registrations, registrationFailures, pluginState, poolMismatch, restarts)
+}
+
+// SetBuildInfo records the version and build date injected at build time.
+func SetBuildInfo(version, buildDate string) {
+	buildInfo.Reset()
+	buildInfo.WithLabelValues(version, buildDate).Set(1)
+}
+
+// SetDevices records the number of advertised and healthy devices of a resource.
+func SetDevices(resource string, devs []*pluginapi.Device) {
+	healthy := 0
+	for _, d := range devs {
+		if d.Health == pluginapi.Healthy {
+			healthy++
+		}
+	}
+	devices.WithLabelValues(resource).Set(float64(len(devs)))
+	healthyDevices.WithLabelValues(resource).Set(float64(healthy))
+}
+
+// ObserveCall records a device plugin API call started at start, which returned err.
+func ObserveCall(resource, method string, start time.Time, err error) {
+	calls.WithLabelValues(resource, method).Inc()
+	callDuration.WithLabelValues(resource, method).Observe(time.Since(start).Seconds())
+	if err != nil {
+		callErrors.WithLabelValues(resource, method).Inc()
+	}
+}
+
+func unaryInterceptor(resource string) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		start := time.Now()
+		resp, err := handler(ctx, req)
+		ObserveCall(resource, path.Base(info.FullMethod), start, err)
+		return resp, err
+	}
+}
+
+func streamInterceptor(resource string) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+		err := handler(srv, ss)
+		ObserveCall(resource, path.Base(info.FullMethod), start, err)
+		return err
+	}
+}
+
+// ServerOptions returns the gRPC server options recording the calls of the kubelet to the
+// device plugin of resource.
+func ServerOptions(resource string) []grpc.ServerOption {
+	return []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(unaryInterceptor(resource)),
+		grpc.ChainStreamInterceptor(streamInterceptor(resource)),
+	}
+}
+
+// ObserveRegistration records an attempt to register with the kubelet, which returned err.
+func ObserveRegistration(resource string, err error) {
+	registrations.WithLabelValues(resource).Inc()
+	if err != nil {
+		registrationFailures.WithLabelValues(resource).Inc()
+	}
+}
+
+// SetPluginState records the state of a plugin monitor.
+func SetPluginState(resource string, state int) {
+	pluginState.WithLabelValues(resource).Set(float64(state))
+}
+
+// SetPoolMismatch records whether the sources of the device pool of a resource differ.
+func SetPoolMismatch(resource string, mismatch bool) {
+	value := 0.0
+	if mismatch {
+		value = 1
+	}
+	poolMismatch.WithLabelValues(resource).Set(value)
+}
+
+// PluginRestarted records a restart triggered by the kubelet socket being re-created.
+func PluginRestarted(resource string) {
+	restarts.WithLabelValues(resource).Inc()
+}
+
+// Serve exposes the metrics on address in the background, see http_endpoint.Serve.
+func Serve(address string) (*http.Server, error) {
+	mux := http.NewServeMux()
+	mux.Handle(MetricsPath, promhttp.HandlerFor(Registry, promhttp.HandlerOpts{}))
+	server, err := http_endpoint.Serve("Metrics", address, mux)
+	if err != nil {
+		return nil, err
+	}
+	glog.V(0).Infof("Serving metrics on http://%s%s", server.Addr, MetricsPath)
+
+	return server, nil
+}
@@ -0,0 +1,129 @@
+// Copyright 2025 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package metrics
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"google.golang.org/grpc"
+	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+)
+
+const testResource = "aws.ec2.nitro/test"
+
+// scrape fetches the metrics from a local endpoint.
+func scrape(t *testing.T) string {
+	t.Helper()
+	server, err := Serve("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Error starting metrics endpoint: %v", err)
+	}
+	defer server.Close()
+
+	resp, err := http.Get("http://" + server.Addr + MetricsPath)
+	if err != nil {
+		t.Fatalf("Error scraping metrics: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("Error reading metrics: %v", err)
+	}
+	return string(body)
+}
+
+func expectMetric(t *testing.T, metrics, line string) {
+	t.Helper()
+	if !strings.Contains(metrics, line+"\n") {
+		t.Errorf("Expected metric %q in:\n%s", line, metrics)
+	}
+}
+
+// value returns the value of a counter, or the number of observations of a histogram.
+func value(t *testing.T, metric prometheus.Metric) float64 {
+	t.Helper()
+	m := &dto.Metric{}
+	if err := metric.Write(m); err != nil {
+		t.Fatalf("Error reading metric: %v", err)
+	}
+	if m.Histogram != nil {
+		return float64(m.GetHistogram().GetSampleCount())
+	}
+	return m.GetCounter().GetValue()
+}
+
+// TestMetricsEndpoint checks the changes of the counters rather than their values, as they are
+// shared by every run of the test in the process.
+func TestMetricsEndpoint(t *testing.T) {
+	counters := map[string]func() prometheus.Metric{
+		"registrations":         func() prometheus.Metric { return registrations.WithLabelValues(testResource) },
+		"registration failures": func() prometheus.Metric { return registrationFailures.WithLabelValues(testResource) },
+		"restarts":              func() prometheus.Metric { return restarts.WithLabelValues(testResource) },
+		"Allocate calls":        func() prometheus.Metric { return calls.WithLabelValues(testResource, "Allocate") },
+		"Allocate errors":       func() prometheus.Metric { return callErrors.WithLabelValues(testResource, "Allocate") },
+		"ListAndWatch calls":    func() prometheus.Metric { return calls.WithLabelValues(testResource, "ListAndWatch") },
+		"Allocate observations": func() prometheus.Metric {
+			return callDuration.WithLabelValues(testResource, "Allocate").(prometheus.Metric)
+		},
+	}
+	before := map[string]float64{}
+	for name, metric := range counters {
+		before[name] = value(t, metric())
+	}
+
+	SetBuildInfo("1.2.3", "today")
+	SetDevices(testResource, []*pluginapi.Device{
+		{ID: "a", Health: pluginapi.Healthy},
+		{ID: "b", Health: pluginapi.Unhealthy},
+		{ID: "c", Health: pluginapi.Healthy},
+	})
+	ObserveRegistration(testResource, nil)
+	ObserveRegistration(testResource, errors.New("kubelet unavailable"))
+	SetPluginState(testResource, 2)
+	SetPoolMismatch(testResource, true)
+	PluginRestarted(testResource)
+
+	unary := unaryInterceptor(testResource)
+	for _, err := range []error{nil, errors.New("allocation failed")} {
+		unary(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: "/v1beta1.DevicePlugin/Allocate"},
+			func(context.Context, interface{}) (interface{}, error) { return nil, err })
+	}
+	stream := streamInterceptor(testResource)
+	stream(nil, nil, &grpc.StreamServerInfo{FullMethod: "/v1beta1.DevicePlugin/ListAndWatch"},
+		func(interface{}, grpc.ServerStream) error { return nil })
+
+	metrics := scrape(t)
+	expectMetric(t, metrics, `nitro_enclaves_device_plugin_build_info{build_date="today",version="1.2.3"} 1`)
+	expectMetric(t, metrics, `nitro_enclaves_device_plugin_devices{resource="aws.ec2.nitro/test"} 3`)
+	expectMetric(t, metrics, `nitro_enclaves_device_plugin_healthy_devices{resource="aws.ec2.nitro/test"} 2`)
+	expectMetric(t, metrics, `nitro_enclaves_device_plugin_state{resource="aws.ec2.nitro/test"} 2`)
+	expectMetric(t, metrics, `nitro_enclaves_device_plugin_pool_mismatch{resource="aws.ec2.nitro/test"} 1`)
+	for _, name := range []string{"registrations_total", "registration_failures_total", "restarts_total"} {
+		if !strings.Contains(metrics, "nitro_enclaves_device_plugin_"+name+`{resource="aws.ec2.nitro/test"}`) {
+			t.Errorf("Expected metric %q in:\n%s", name, metrics)
+		}
+	}
+
+	for name, want := range map[string]float64{
+		"registrations":         2,
+		"registration failures": 1,
+		"restarts":              1,
+		"Allocate calls":        2,
+		"Allocate errors":       1,
+		"ListAndWatch calls":    1,
+		"Allocate observations": 2,
+	} {
+		if got := value(t, counters[name]()) - before[name]; got != want {
+			t.Errorf("Expected %s to increase by %v, got %v", name, want, got)
+		}
+	}
+}
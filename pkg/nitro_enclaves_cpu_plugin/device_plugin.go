@@ -6,263 +6,307 @@ package nitro_enclaves_cpu_plugin
 import (
 	"errors"
 	"fmt"
-	"google.golang.org/grpc/credentials/insecure"
+	"k8s-ne-device-plugin/pkg/allocator"
 	"k8s-ne-device-plugin/pkg/config"
-	"k8s-ne-device-plugin/pkg/nitro_enclaves_device_monitor"
-	"net"
+	"k8s-ne-device-plugin/pkg/cpulist"
+	"k8s-ne-device-plugin/pkg/device_plugin_framework"
 	"os"
-	"path"
+	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/golang/glog"
-	"golang.org/x/net/context"
-	"google.golang.org/grpc"
 	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
 )
 
 const (
-	deviceName                     = "nitro_enclaves_cpus"
-	devicePluginServerReadyTimeout = 10
-	deviceOfflineCPUsPath          = "/sys/devices/system/cpu/offline"
+	deviceName             = "nitro_enclaves_cpus"
+	offlineCPUsFile        = "offline"
+	onlineCPUsFile         = "online"
+	cpuPoolRefreshInterval = 10 * time.Second
+	cpuDeviceIDPrefix      = "cpu_"
+
+	// Paths relative to the sysfs root, see config.PluginConfig.SysPath.
+	deviceCPUSysfsPath  = "devices/system/cpu"
+	deviceNodeSysfsPath = "devices/system/node"
+	neCPUsParamPath     = "module/nitro_enclaves/parameters/ne_cpus"
+
+	// Environment variables injected into containers allocating enclave CPUs.
+	enclaveCPUsEnv   = "NITRO_ENCLAVES_CPUS"
+	enclaveCPUIDsEnv = "NITRO_ENCLAVES_CPU_IDS"
 )
 
-var cpuIdCounter = 0
-
-// NitroEnclavesCPUDevicePlugin implements the Kubernetes device plugin API
+// NitroEnclavesCPUDevicePlugin advertises the enclave CPU pool as "aws.ec2.nitro/nitro_enclaves_cpus".
 type NitroEnclavesCPUDevicePlugin struct {
-	devices []*pluginapi.Device
+	*device_plugin_framework.Plugin
+	*device_plugin_framework.DevicePool
 
-	stop chan interface{}
+	// topology holds the core and sibling information of each CPU ever seen online or in the
+	// pool, see cacheOnlineTopology.
+	topology      map[int]cpuTopology
+	cpuSysfsPath  string
+	nodeSysfsPath string
 
-	server *grpc.Server
-	pluginapi.DevicePluginServer
+	allocatorConfigPath string
+	neCPUsPath          string
 
-	nitro_enclaves_device_monitor.IBasicDevicePlugin
+	// mutex guards topology and allocatorConfigPath.
+	mutex sync.Mutex
 }
 
-func (necdp *NitroEnclavesCPUDevicePlugin) socketPath() string {
-	return pluginapi.DevicePluginPath + deviceName + ".sock"
+// DeviceName returns the name of the resource within the aws.ec2.nitro namespace.
+func (necdp *NitroEnclavesCPUDevicePlugin) DeviceName() string {
+	return deviceName
 }
 
-func (necdp *NitroEnclavesCPUDevicePlugin) ResourceName() string {
-	return "aws.ec2.nitro/" + deviceName
+// generateEnclaveCPUID derives the device ID of an enclave CPU from its CPU number.
+func generateEnclaveCPUID(cpu int) string {
+	return cpuDeviceIDPrefix + strconv.Itoa(cpu)
 }
 
-// determineAdvisableCPUs reads the number of offline cpus from /sys/devices/system/cpu/offline
-func determineAdvisableCPUs(data string) (int, error) {
-
-	// Handle empty/unknown case
-	content := strings.TrimSpace(data)
-	if content == "" {
-		return 0, nil
+// parseEnclaveCPUID returns the CPU number of an enclave CPU device ID.
+func parseEnclaveCPUID(id string) (int, error) {
+	cpu, err := strconv.Atoi(strings.TrimPrefix(id, cpuDeviceIDPrefix))
+	if err != nil || !strings.HasPrefix(id, cpuDeviceIDPrefix) || cpu < 0 {
+		return 0, fmt.Errorf("invalid enclave CPU device ID: %s", id)
 	}
+	return cpu, nil
+}
 
-	total := 0
-	ranges := strings.Split(content, ",")
-
-	for _, r := range ranges {
-		parts := strings.Split(r, "-")
-		switch len(parts) {
-		case 1:
-			// Single CPU
-			// Ensure that parts is a valid number
-			_, err := strconv.Atoi(parts[0])
-			if err != nil {
-				return 0, fmt.Errorf("invalid CPU number: %s, parsing caused error: %w", r, err)
-			}
-			total++
-		case 2:
-			// CPU range
-			start, err1 := strconv.Atoi(parts[0])
-			end, err2 := strconv.Atoi(parts[1])
-			if err1 != nil || err2 != nil {
-				return 0, fmt.Errorf("invalid CPU range: %s", r)
-			}
-			total += end - start + 1
-		default:
-			return 0, fmt.Errorf("malformed CPU range: %s", r)
+// parseEnclaveCPUIDs returns the CPUs of a list of enclave CPU device IDs.
+func parseEnclaveCPUIDs(ids []string) (cpulist.CPUSet, error) {
+	cpus := make([]int, 0, len(ids))
+	for _, id := range ids {
+		cpu, err := parseEnclaveCPUID(id)
+		if err != nil {
+			return cpulist.CPUSet{}, err
 		}
+		cpus = append(cpus, cpu)
 	}
-
-	return total, nil
+	return cpulist.New(cpus...), nil
 }
 
-func generateEnclaveCPUID(deviceName string) string {
-	ctr := cpuIdCounter
-	cpuIdCounter++
-	return deviceName + "_" + strconv.Itoa(ctr)
+// cacheOnlineTopology reads the topology of the online CPUs not seen before. The kernel
+// removes the topology of CPUs going offline, so it is kept for CPUs joining the pool later
+// on and serves to infer the cores of the others, see inferCoreTopology. Must be called with
+// the mutex held.
+func (necdp *NitroEnclavesCPUDevicePlugin) cacheOnlineTopology() {
+	online, err := readCPUList(filepath.Join(necdp.cpuSysfsPath, onlineCPUsFile))
+	if err != nil {
+		glog.V(1).Infof("Unable to read the online CPUs: %v", err)
+		return
+	}
+	for _, cpu := range online.List() {
+		if t, ok := necdp.topology[cpu]; ok && t.known {
+			continue
+		}
+		if t := readCPUTopology(necdp.cpuSysfsPath, necdp.nodeSysfsPath, cpu); t.known {
+			necdp.topology[cpu] = t
+		}
+	}
 }
 
-// Register the device plugin with Kubelet.
-func (necdp *NitroEnclavesCPUDevicePlugin) register(kubeletEndpoint, resourceName string) error {
-	glog.V(0).Info("Attempting to connect to kubelet...")
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
-
-	opts := []grpc.DialOption{
-		grpc.WithTransportCredentials(insecure.NewCredentials()),
-		//lint:ignore SA1019 grpc.WithBlock is deprecated, not supported by grpc.NewClient
-		grpc.WithBlock(),
-		grpc.WithContextDialer(func(ctx context.Context, addr string) (net.Conn, error) {
-			return net.DialUnix("unix", nil, &net.UnixAddr{Name: addr, Net: "unix"})
-		}),
+// updatePool rebuilds the device list from the given enclave CPU pool, see
+// device_plugin_framework.DevicePool. Returns whether the advertised device list changed.
+func (necdp *NitroEnclavesCPUDevicePlugin) updatePool(pool cpulist.CPUSet) bool {
+	necdp.mutex.Lock()
+	necdp.cacheOnlineTopology()
+	devs := make([]*pluginapi.Device, 0, pool.Size())
+	for _, cpu := range pool.List() {
+		topology, seen := necdp.topology[cpu]
+		if !topology.known {
+			topology = lookupCPUTopology(necdp.cpuSysfsPath, necdp.nodeSysfsPath, cpu, necdp.topology)
+			if !topology.known && !seen {
+				glog.Warningf("Hyperthread siblings of enclave CPU %d are unknown, treating it as a core of its own", cpu)
+			}
+			necdp.topology[cpu] = topology
+		}
+		devs = append(devs, &pluginapi.Device{ID: generateEnclaveCPUID(cpu), Topology: topology.topologyInfo()})
 	}
+	necdp.mutex.Unlock()
 
-	//lint:ignore SA1019 grpc.DialContext is deprecated // todo replace by grpc.NewClient
-	conn, err := grpc.DialContext(
-		ctx,
-		kubeletEndpoint,
-		opts...,
-	)
-	defer func(conn *grpc.ClientConn) {
-		err := conn.Close()
-		if err != nil {
-			glog.Errorf("Error closing connection to kubelet: %v", err)
-		}
-	}(conn)
+	return necdp.UpdatePool(devs)
+}
 
+// refresh re-reads the enclave CPU pool and notifies all active ListAndWatch streams if the
+// advertised device list changed. A pool that cannot be read keeps the current device list.
+func (necdp *NitroEnclavesCPUDevicePlugin) refresh() {
+	pool, err := necdp.readCPUPool()
 	if err != nil {
-		glog.Errorf("Couldn't connect to kubelet! (Reason: %s)", err)
-		return err
+		glog.Errorf("Error while determining advisable CPUs on the instance: %v", err)
+		return
 	}
 
-	glog.V(0).Info("Connected to kubelet")
+	if necdp.updatePool(pool) {
+		glog.V(0).Infof("Enclave CPU pool changed, advertising CPUs: %v", pool)
+		necdp.Update()
+	}
+}
 
-	client := pluginapi.NewRegistrationClient(conn)
-	_, err = client.Register(ctx, &pluginapi.RegisterRequest{
-		Version:      pluginapi.Version,
-		Endpoint:     path.Base(necdp.socketPath()),
-		ResourceName: resourceName,
-	})
+// Reconfigure applies a reloaded plugin config and re-reads the enclave CPU pool, notifying
+// all active ListAndWatch streams if the advertised device list changed.
+func (necdp *NitroEnclavesCPUDevicePlugin) Reconfigure(config *config.PluginConfig) {
+	necdp.mutex.Lock()
+	necdp.allocatorConfigPath = config.AllocatorConfigPath
+	necdp.mutex.Unlock()
 
-	return err
+	necdp.refresh()
 }
 
-// Ensure that the gRPC server of the device plugin is ready to serve.
-func (necdp *NitroEnclavesCPUDevicePlugin) waitForServerReady(timeout int) error {
-	for i := 0; i < timeout; i++ {
-		info := necdp.server.GetServiceInfo()
-		if len(info) >= 1 {
-			return nil
-		}
-		time.Sleep(time.Second)
+// readCPUList reads a sysfs file in cpulist format.
+func readCPUList(path string) (cpulist.CPUSet, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return cpulist.CPUSet{}, err
 	}
-
-	return errors.New("gRPC server initialization timed out")
+	return cpulist.Parse(string(data))
 }
 
-// Allocate is called during container creation so that the Device
-// Plugin can run device specific operations and instruct Kubelet
-// of the steps to make the Device available in the container
-func (necdp *NitroEnclavesCPUDevicePlugin) Allocate(ctx context.Context, reqs *pluginapi.AllocateRequest) (*pluginapi.AllocateResponse, error) {
-	responses := pluginapi.AllocateResponse{}
-
-	for _, req := range reqs.ContainerRequests {
-		responses.ContainerResponses = append(responses.ContainerResponses, &pluginapi.ContainerAllocateResponse{
-			Envs: map[string]string{
-				"NITRO_ENCLAVES_CPUS": strconv.Itoa(len(req.DevicesIDs)),
-			},
-		})
+// readCPUPool determines the enclave CPU pool. The ne_cpus parameter of the nitro_enclaves
+// driver is the source of truth, restricted to CPUs which are actually offline, as other
+// offline CPUs (e.g. disabled SMT siblings) can't be used by enclaves. Without ne_cpus all
+// offline CPUs are considered, and if sysfs can't be read at all, the cpu_pool of the
+// allocator config is used. The pool is cross-checked against the allocator config.
+func (necdp *NitroEnclavesCPUDevicePlugin) readCPUPool() (cpulist.CPUSet, error) {
+	necdp.mutex.Lock()
+	allocatorConfigPath := necdp.allocatorConfigPath
+	necdp.mutex.Unlock()
+
+	allocatorConfig, allocatorErr := allocator.LoadConfig(allocatorConfigPath)
+	if allocatorErr != nil {
+		glog.V(1).Infof("Unable to read allocator config: %v", allocatorErr)
 	}
 
-	return &responses, nil
-}
+	offline, offlineErr := readCPUList(filepath.Join(necdp.cpuSysfsPath, offlineCPUsFile))
+	if offlineErr != nil {
+		glog.Errorf("Error reading offline CPU file: %v", offlineErr)
+	}
+	neCPUs, neCPUsErr := readCPUList(necdp.neCPUsPath)
+	if neCPUsErr == nil && neCPUs.IsEmpty() {
+		neCPUsErr = errors.New("ne_cpus is not set")
+	}
+	if neCPUsErr != nil {
+		glog.V(1).Infof("Unable to read the nitro_enclaves driver CPU pool: %v", neCPUsErr)
+	}
 
-// GetDevicePluginOptions returns options to be communicated with Device Manager.
-func (*NitroEnclavesCPUDevicePlugin) GetDevicePluginOptions(context.Context, *pluginapi.Empty) (*pluginapi.DevicePluginOptions, error) {
-	return &pluginapi.DevicePluginOptions{}, nil
-}
+	var pool cpulist.CPUSet
+	var mismatches []error
+	switch {
+	case offlineErr == nil && neCPUsErr == nil:
+		pool = neCPUs.Intersection(offline)
+		if notInPool := offline.Difference(neCPUs); !notInPool.IsEmpty() {
+			mismatches = append(mismatches, fmt.Errorf("offline CPUs %q are not part of the nitro_enclaves ne_cpus pool and are not advertised", notInPool))
+		}
+		if online := neCPUs.Difference(offline); !online.IsEmpty() {
+			mismatches = append(mismatches, fmt.Errorf("nitro_enclaves ne_cpus %q are unexpectedly online and are not advertised", online))
+		}
+	case offlineErr == nil:
+		pool = offline
+	case neCPUsErr == nil:
+		glog.V(0).Infof("Falling back to the nitro_enclaves ne_cpus: %v", neCPUs)
+		pool = neCPUs
+	case allocatorConfig != nil && allocatorConfig.CPUPool != "":
+		glog.V(0).Infof("Falling back to the allocator cpu_pool: %v", allocatorConfig.CPUs())
+		return allocatorConfig.CPUs(), nil
+	default:
+		return cpulist.CPUSet{}, fmt.Errorf("no enclave CPU pool available from sysfs (%v) or allocator config (%v)", offlineErr, allocatorErr)
+	}
 
-// GetPreferredAllocation returns a preferred set of devices to allocate
-// from a list of available ones. The resulting preferred allocation is not
-// guaranteed to be the allocation ultimately performed by the
-// devicemanager. It is only designed to help the devicemanager make a more
-// informed allocation decision when possible.
-func (*NitroEnclavesCPUDevicePlugin) GetPreferredAllocation(context.Context, *pluginapi.PreferredAllocationRequest) (*pluginapi.PreferredAllocationResponse, error) {
-	return &pluginapi.PreferredAllocationResponse{}, nil
+	if allocatorConfig != nil {
+		if err := allocatorConfig.CheckCPUs(pool); err != nil {
+			mismatches = append(mismatches, err)
+		}
+	}
+	necdp.SetPoolMismatch(errors.Join(mismatches...))
+
+	return pool, nil
 }
 
-// ListAndWatch returns a stream of List of Devices
-// Whenever a Device state change or a Device disappears, ListAndWatch
-// returns the new list
-func (necdp *NitroEnclavesCPUDevicePlugin) ListAndWatch(e *pluginapi.Empty, s pluginapi.DevicePlugin_ListAndWatchServer) error {
-	err := s.Send(&pluginapi.ListAndWatchResponse{Devices: necdp.devices})
+// Watch refreshes the enclave CPU pool on CPU hotplug uevents and, as a fallback for
+// environments where uevents are not delivered, periodically.
+func (necdp *NitroEnclavesCPUDevicePlugin) Watch(stop <-chan interface{}) {
+	events, err := watchCPUHotplugEvents(stop)
 	if err != nil {
-		return err
+		glog.Errorf("Error while listening for CPU hotplug events, falling back to polling: %v", err)
 	}
 
-	<-necdp.stop
-	return nil
-
-}
+	ticker := time.NewTicker(cpuPoolRefreshInterval)
+	defer ticker.Stop()
 
-// PreStartContainer is called, if indicated by Device Plugin during registration phase,
-// before each container start. Device plugin can run device specific operations
-// such as resetting the device before making devices available to the container.
-func (necdp *NitroEnclavesCPUDevicePlugin) PreStartContainer(context.Context, *pluginapi.PreStartContainerRequest) (*pluginapi.PreStartContainerResponse, error) {
-	return &pluginapi.PreStartContainerResponse{}, nil
+	for {
+		select {
+		case <-events:
+		case <-ticker.C:
+		case <-stop:
+			return
+		}
+		necdp.refresh()
+	}
 }
 
-func (necdp *NitroEnclavesCPUDevicePlugin) releaseResources() {
-	necdp.server = nil
-	// check if socketPath does exist and delete otherwise do nothing
-	_, err := os.Stat(necdp.socketPath())
-	if err == nil {
-		err = os.Remove(necdp.socketPath())
-		if err != nil {
-			glog.Errorf("Error removing socket file: %s", err)
+// ContainerAllocate injects the number and IDs of the allocated enclave CPUs into the container.
+// IDs of CPUs which were never advertised are rejected.
+func (necdp *NitroEnclavesCPUDevicePlugin) ContainerAllocate(req *pluginapi.ContainerAllocateRequest) (*pluginapi.ContainerAllocateResponse, error) {
+	allocated, err := parseEnclaveCPUIDs(req.DevicesIDs)
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range req.DevicesIDs {
+		if !necdp.HasDevice(id) {
+			return nil, fmt.Errorf("unknown enclave CPU device ID: %s", id)
 		}
 	}
+	glog.V(1).Infof("Allocation request for enclave CPUs: %v", allocated)
+
+	return &pluginapi.ContainerAllocateResponse{
+		Envs: map[string]string{
+			enclaveCPUsEnv:   strconv.Itoa(len(req.DevicesIDs)),
+			enclaveCPUIDsEnv: allocated.String(),
+		},
+	}, nil
 }
 
-// Start device plugin server
-func (necdp *NitroEnclavesCPUDevicePlugin) Start() error {
-	necdp.releaseResources()
-	glog.V(0).Info("Starting Nitro Enclaves CPU device plugin server...")
+// PreferredAllocation prefers whole physical cores, as enclaves need complete hyperthread
+// sibling groups on the same core and package.
+func (necdp *NitroEnclavesCPUDevicePlugin) PreferredAllocation(req *pluginapi.ContainerPreferredAllocationRequest) ([]string, error) {
+	necdp.mutex.Lock()
+	topology := make(map[int]cpuTopology, len(necdp.topology))
+	for cpu, t := range necdp.topology {
+		topology[cpu] = t
+	}
+	necdp.mutex.Unlock()
 
-	sock, err := net.Listen("unix", necdp.socketPath())
+	available, err := parseEnclaveCPUIDs(req.AvailableDeviceIDs)
 	if err != nil {
-		glog.Error("Error while creating socket: ", necdp.socketPath())
-		return err
+		return nil, err
 	}
-
-	necdp.server = grpc.NewServer([]grpc.ServerOption{}...)
-	pluginapi.RegisterDevicePluginServer(necdp.server, necdp)
-	go func() {
-		err := necdp.server.Serve(sock)
-		if err != nil {
-			if necdp.stop != nil {
-				glog.Errorf("Error while serving device plugin: %v", err)
-				close(necdp.stop)
-			}
-		}
-	}()
-	err = necdp.waitForServerReady(devicePluginServerReadyTimeout)
+	mustInclude, err := parseEnclaveCPUIDs(req.MustIncludeDeviceIDs)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	if err = necdp.register(pluginapi.KubeletSocket, necdp.ResourceName()); err != nil {
-		glog.Errorf("Error while registering cpu device plugin with kubelet! (Reason: %s)", err)
-		necdp.Stop()
-		return err
-	}
-	glog.V(0).Info("Registered cpu device plugin with Kubelet: ", necdp.ResourceName())
+	preferred := preferCPUs(available, mustInclude, int(req.AllocationSize), topology)
+	glog.V(1).Infof("Preferred enclave CPU allocation: %v", preferred)
 
-	return nil
+	ids := make([]string, 0, preferred.Size())
+	for _, cpu := range preferred.List() {
+		ids = append(ids, generateEnclaveCPUID(cpu))
+	}
+	return ids, nil
 }
 
-// Stop device plugin server
-func (necdp *NitroEnclavesCPUDevicePlugin) Stop() {
-	close(necdp.stop)
-	if necdp.server != nil {
-		necdp.server.Stop()
-		necdp.releaseResources()
-		necdp.server = nil
+// newCPUPoolReader returns a plugin which reads the enclave CPU pool, but can't serve it yet.
+func newCPUPoolReader(config *config.PluginConfig) *NitroEnclavesCPUDevicePlugin {
+	return &NitroEnclavesCPUDevicePlugin{
+		DevicePool:          device_plugin_framework.NewDevicePool(deviceName, "enclave CPU pool"),
+		topology:            make(map[int]cpuTopology),
+		cpuSysfsPath:        config.SysPath(deviceCPUSysfsPath),
+		nodeSysfsPath:       config.SysPath(deviceNodeSysfsPath),
+		allocatorConfigPath: config.AllocatorConfigPath,
+		neCPUsPath:          config.SysPath(neCPUsParamPath),
 	}
-	glog.V(0).Infof("CPU device plugin stopped. (Socket: %s)", necdp.socketPath())
 }
 
 // NewNitroEnclavesCPUDevicePlugin returns an initialized NitroEnclavesCPUDevicePlugin
@@ -274,36 +318,16 @@ func NewNitroEnclavesCPUDevicePlugin(config *config.PluginConfig) *NitroEnclaves
 
 	glog.V(0).Infof("Initializing Nitro Enclaves CPU device plugin with following params: %v", config)
 
-	// create a virtual device for each 'offline' cpu on the kubernetes worker. An offline CPU can be considered a
-	// CPU that is not in use by the host OS and has thus been allocated by the AWS Nitro Enclave allocation service.
-	var devs []*pluginapi.Device
-	if config.EnclaveCPUAdvertisement {
-
-		data, err := os.ReadFile(deviceOfflineCPUsPath)
-		if err != nil {
-			glog.V(0).Infof("Error reading offline CPU file: %v", err)
-			// if error was thrown in read CPU file step, set data to empty string to have
-			// determineAdvisableCPUs set availableCPUsOnInstance to 0
-			data = []byte("")
-		}
-
-		availableCPUsOnInstance, err := determineAdvisableCPUs(string(data))
-		if err != nil {
-			glog.V(0).Infof("Error while determining advisable CPUs on the instance: %v", err)
-			availableCPUsOnInstance = 0
-		}
+	necdp := newCPUPoolReader(config)
+	necdp.Plugin = device_plugin_framework.NewPlugin(necdp, config.DevicePluginPath())
 
-		for i := 0; i < availableCPUsOnInstance; i++ {
-			devs = append(devs, &pluginapi.Device{
-				ID:     generateEnclaveCPUID(deviceName),
-				Health: pluginapi.Healthy,
-			})
-		}
-		glog.V(0).Infof("Reserved CPUs for encalves added: %v", availableCPUsOnInstance)
+	// create a virtual device for each 'offline' cpu on the kubernetes worker, which is part of the nitro_enclaves
+	// driver CPU pool. Such a CPU is not in use by the host OS and has been allocated by the AWS Nitro Enclave
+	// allocation service. The pool is re-read at runtime, see Watch.
+	if config.EnclaveCPUAdvertisement {
+		necdp.refresh()
+		glog.V(0).Infof("Reserved CPUs for encalves added: %v", len(necdp.Devices()))
 	}
 
-	return &NitroEnclavesCPUDevicePlugin{
-		devices: devs,
-		stop:    make(chan interface{}),
-	}
+	return necdp
 }
@@ -4,113 +4,69 @@ package nitro_enclaves_cpu_plugin
 
 import (
 	"k8s-ne-device-plugin/pkg/config"
+	"k8s-ne-device-plugin/pkg/cpulist"
+	"k8s-ne-device-plugin/pkg/fake_kubelet"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
 )
 
-func TestDetermineAdvisableCPUs(t *testing.T) {
-	tests := []struct {
-		name    string
-		input   string
-		want    int
-		wantErr bool
-	}{
-		{
-			name:    "empty content",
-			input:   "",
-			want:    0,
-			wantErr: false,
-		},
-		{
-			name:    "single CPU",
-			input:   "1",
-			want:    1,
-			wantErr: false,
-		},
-		{
-			name:    "multiple single CPUs",
-			input:   "1,2,3,20",
-			want:    4,
-			wantErr: false,
-		},
-		{
-			name:    "corrupt file",
-			input:   "1,2,",
-			want:    2,
-			wantErr: true,
-		},
-		{
-			name:    "CPU range",
-			input:   "1-3",
-			want:    3,
-			wantErr: false,
-		},
-		{
-			name:    "CPU ranges",
-			input:   "1-3,5-7",
-			want:    6,
-			wantErr: false,
-		},
-		{
-			name:    "CPU range with single",
-			input:   "1-3,9",
-			want:    4,
-			wantErr: false,
-		},
-		{
-			name:    "multiple ranges",
-			input:   "1-3,5,7-8",
-			want:    6,
-			wantErr: false,
-		},
-		{
-			name:    "invalid range",
-			input:   "1-3-4",
-			wantErr: true,
-		},
-		{
-			name:    "invalid number",
-			input:   "a-b",
-			wantErr: true,
-		},
+// Enclave CPU device IDs are derived from the CPU number and can be mapped back to it.
+func TestEnclaveCPUIDs(t *testing.T) {
+	id := generateEnclaveCPUID(5)
+	if id != "cpu_5" {
+		t.Fatalf("Expected cpu_5 but got invalid id: %s!", id)
 	}
 
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-
-			got, err := determineAdvisableCPUs(tt.input)
-
-			if (err != nil) != tt.wantErr {
-				t.Errorf("determineAdvisableCPUs() error = %v, wantErr %v", err, tt.wantErr)
-				return
-			}
+	cpu, err := parseEnclaveCPUID(id)
+	if err != nil || cpu != 5 {
+		t.Fatalf("Expected CPU 5 but got %d (error: %v)!", cpu, err)
+	}
 
-			if !tt.wantErr && got != tt.want {
-				t.Errorf("determineAdvisableCPUs() = %v, want %v", got, tt.want)
-			}
-		})
+	for _, invalid := range []string{"nitro_enclaves_cpus_5", "cpu_", "cpu_-1", "5"} {
+		if _, err := parseEnclaveCPUID(invalid); err == nil {
+			t.Errorf("Expected an error for invalid id %s!", invalid)
+		}
 	}
 }
 
-func TestIncrementalDeviceIdGenerationSuccess(t *testing.T) {
-	deviceName := "dummy_cpu"
-	expected := "dummy_cpu_0"
-
-	id := generateEnclaveCPUID(deviceName)
+func TestAllocateInjectsCPUIDs(t *testing.T) {
+	p := NewNitroEnclavesCPUDevicePlugin(&config.PluginConfig{MaxEnclavesPerNode: 4})
+	p.updatePool(cpulist.New(4, 5, 7, 12, 13))
 
-	if expected != id {
-		t.Fatalf("Expected %s but got invalid id: %s!", expected, id)
-		return
+	resp, err := p.Allocate(context.Background(), &pluginapi.AllocateRequest{
+		ContainerRequests: []*pluginapi.ContainerAllocateRequest{
+			{DevicesIDs: []string{"cpu_13", "cpu_4", "cpu_12", "cpu_5"}},
+			{DevicesIDs: []string{"cpu_7"}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Allocate() error = %v", err)
 	}
 
-	cpuIdCounter = 99
-	_ = generateEnclaveCPUID(deviceName)
-	deviceName = "dummy_cpu2"
-	expected = "dummy_cpu2_100"
-	id = generateEnclaveCPUID(deviceName)
+	want := []map[string]string{
+		{enclaveCPUsEnv: "4", enclaveCPUIDsEnv: "4-5,12-13"},
+		{enclaveCPUsEnv: "1", enclaveCPUIDsEnv: "7"},
+	}
+	for i, r := range resp.ContainerResponses {
+		if !reflect.DeepEqual(r.Envs, want[i]) {
+			t.Errorf("Allocate() envs = %v, want %v", r.Envs, want[i])
+		}
+	}
 
-	if expected != id {
-		t.Fatalf("Expected %s but got invalid id: %s!", expected, id)
-		return
+	for _, id := range []string{"bogus", "cpu_6"} {
+		_, err = p.Allocate(context.Background(), &pluginapi.AllocateRequest{
+			ContainerRequests: []*pluginapi.ContainerAllocateRequest{{DevicesIDs: []string{"cpu_4", id}}},
+		})
+		if err == nil {
+			t.Errorf("Expected Allocate() to fail for the unknown device ID %s", id)
+		}
 	}
 }
 
@@ -118,8 +74,161 @@ func TestValidateDeviceNameSuccess(t *testing.T) {
 	p := NewNitroEnclavesCPUDevicePlugin(&config.PluginConfig{MaxEnclavesPerNode: 4, EnclaveCPUAdvertisement: false})
 
 	// enclave cpu advertisement is disabled
-	if len(p.devices) != 0 {
-		t.Fatalf("Expected %v but got invalid id: %v!", 0, len(p.devices))
+	if len(p.Devices()) != 0 {
+		t.Fatalf("Expected %v but got invalid id: %v!", 0, len(p.Devices()))
 		return
 	}
 }
+
+func healthByID(devs []*pluginapi.Device) map[string]string {
+	health := map[string]string{}
+	for _, d := range devs {
+		health[d.ID] = d.Health
+	}
+	return health
+}
+
+// CPUs leaving the pool must be marked unhealthy instead of disappearing, CPUs joining the
+// pool must be added and CPUs returning to the pool must become healthy again.
+func TestRefreshFollowsCPUPool(t *testing.T) {
+	sysfs := t.TempDir()
+	offline := filepath.Join(sysfs, offlineCPUsFile)
+	if err := os.WriteFile(offline, []byte("2-3\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	p := NewNitroEnclavesCPUDevicePlugin(&config.PluginConfig{MaxEnclavesPerNode: 4})
+	p.cpuSysfsPath = sysfs
+	p.neCPUsPath = filepath.Join(sysfs, "ne_cpus")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stream := fake_kubelet.NewListAndWatchServer(ctx)
+	go p.ListAndWatch(&pluginapi.Empty{}, stream)
+
+	next := func() map[string]string {
+		t.Helper()
+		select {
+		case devs := <-stream.Updates:
+			return healthByID(devs)
+		case <-time.After(2 * time.Second):
+			t.Fatal("Timed out waiting for a CPU device list!")
+			return nil
+		}
+	}
+
+	if devs := next(); len(devs) != 0 {
+		t.Fatalf("Expected no CPU devices but got %v!", devs)
+	}
+
+	p.refresh()
+	devs := next()
+	if len(devs) != 2 {
+		t.Fatalf("Expected 2 CPU devices but got %v!", devs)
+	}
+	cpu2, cpu3 := "cpu_2", "cpu_3"
+
+	if err := os.WriteFile(offline, []byte("2,4-5\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	p.refresh()
+	devs = next()
+	if len(devs) != 4 || devs[cpu3] != pluginapi.Unhealthy || devs[cpu2] != pluginapi.Healthy {
+		t.Fatalf("Expected CPU 3 to be unhealthy and CPUs 2, 4, 5 healthy but got %v!", devs)
+	}
+
+	if err := os.WriteFile(offline, []byte("2-5\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	p.refresh()
+	for id, health := range next() {
+		if health != pluginapi.Healthy {
+			t.Fatalf("Expected %s to be healthy again but got %s!", id, health)
+		}
+	}
+
+	// An unchanged pool must not produce an update.
+	p.refresh()
+	select {
+	case devs := <-stream.Updates:
+		t.Fatalf("Expected no update for an unchanged pool but got %v!", healthByID(devs))
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+// The allocator config is cross-checked against sysfs and used as fallback if sysfs can't be read.
+func TestAllocatorConfigCrossCheckAndFallback(t *testing.T) {
+	dir := t.TempDir()
+	allocatorConfig := filepath.Join(dir, "allocator.yaml")
+	if err := os.WriteFile(allocatorConfig, []byte("memory_mib: 512\ncpu_pool: 2-3\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	p := NewNitroEnclavesCPUDevicePlugin(&config.PluginConfig{MaxEnclavesPerNode: 4, AllocatorConfigPath: allocatorConfig})
+	p.cpuSysfsPath = dir
+	p.neCPUsPath = filepath.Join(dir, "ne_cpus")
+	if err := os.WriteFile(filepath.Join(dir, offlineCPUsFile), []byte("2-5\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	p.refresh()
+	if len(p.Devices()) != 4 {
+		t.Fatalf("Expected the 4 CPUs of sysfs to be advertised but got %v", healthByID(p.Devices()))
+	}
+	if p.PoolMismatch() == nil {
+		t.Fatal("Expected a mismatch between allocator cpu_pool and sysfs")
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, offlineCPUsFile), []byte("2-3\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	p.refresh()
+	if p.PoolMismatch() != nil {
+		t.Fatalf("Expected no mismatch but got %v", p.PoolMismatch())
+	}
+
+	fallback := NewNitroEnclavesCPUDevicePlugin(&config.PluginConfig{MaxEnclavesPerNode: 4, AllocatorConfigPath: allocatorConfig})
+	fallback.cpuSysfsPath = filepath.Join(dir, "missing")
+	fallback.neCPUsPath = filepath.Join(dir, "missing", "ne_cpus")
+	fallback.refresh()
+	devs := healthByID(fallback.Devices())
+	if len(devs) != 2 || devs["cpu_2"] != pluginapi.Healthy || devs["cpu_3"] != pluginapi.Healthy {
+		t.Fatalf("Expected the allocator cpu_pool to be advertised but got %v", devs)
+	}
+}
+
+// Only offline CPUs which are part of the nitro_enclaves driver CPU pool are advertised.
+func TestNECPUsRestrictTheCPUPool(t *testing.T) {
+	dir := t.TempDir()
+	p := NewNitroEnclavesCPUDevicePlugin(&config.PluginConfig{MaxEnclavesPerNode: 4, AllocatorConfigPath: filepath.Join(dir, "allocator.yaml")})
+	p.cpuSysfsPath = dir
+	p.neCPUsPath = filepath.Join(dir, "ne_cpus")
+
+	// CPUs 6-7 are administratively offline, CPU 3 is part of the pool but online.
+	if err := os.WriteFile(filepath.Join(dir, offlineCPUsFile), []byte("1-2,5-7\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(p.neCPUsPath, []byte("1-3,5\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	p.refresh()
+	devs := healthByID(p.Devices())
+	if len(devs) != 3 || devs["cpu_1"] == "" || devs["cpu_2"] == "" || devs["cpu_5"] == "" {
+		t.Fatalf("Expected CPUs 1, 2 and 5 to be advertised but got %v", devs)
+	}
+
+	mismatch := p.PoolMismatch()
+	if mismatch == nil || !strings.Contains(mismatch.Error(), `"6-7"`) || !strings.Contains(mismatch.Error(), `"3"`) {
+		t.Fatalf("Expected CPUs 6-7 and 3 to be reported but got %v", mismatch)
+	}
+
+	// An unset ne_cpus parameter falls back to all offline CPUs.
+	if err := os.WriteFile(p.neCPUsPath, []byte("\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	p.refresh()
+	if devs := healthByID(p.Devices()); len(devs) != 5 {
+		t.Fatalf("Expected all 5 offline CPUs to be advertised but got %v", devs)
+	}
+}
@@ -0,0 +1,74 @@
+// Copyright 2026 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package nitro_enclaves_cpu_plugin
+
+import (
+	"k8s-ne-device-plugin/pkg/config"
+	"k8s-ne-device-plugin/pkg/cpulist"
+	"k8s-ne-device-plugin/pkg/doctor"
+	"path/filepath"
+)
+
+// Diagnose checks the nitro_enclaves driver CPU pool, the offline CPUs and the enclave CPU
+// pool the plugin advertises from them.
+func Diagnose(config *config.PluginConfig) []doctor.Result {
+	necdp := newCPUPoolReader(config)
+	results := []doctor.Result{}
+
+	neCPUs, err := readCPUList(necdp.neCPUsPath)
+	switch {
+	case err != nil:
+		results = append(results, doctor.Failed("ne_cpus", "nitro_enclaves driver CPU pool can't be read: %v", err))
+	case neCPUs.IsEmpty():
+		results = append(results, doctor.Failed("ne_cpus", "no CPUs are reserved for enclaves in %s", necdp.neCPUsPath))
+	default:
+		results = append(results, doctor.Passed("ne_cpus", "CPUs %v are reserved for enclaves", neCPUs))
+	}
+
+	offline, err := readCPUList(filepath.Join(necdp.cpuSysfsPath, offlineCPUsFile))
+	switch {
+	case err != nil:
+		results = append(results, doctor.Failed("offline CPUs", "offline CPUs can't be read: %v", err))
+	case offline.IsEmpty():
+		results = append(results, doctor.Failed("offline CPUs", "no CPUs are offline"))
+	default:
+		results = append(results, doctor.Passed("offline CPUs", "CPUs %v are offline", offline))
+	}
+
+	pool, err := necdp.readCPUPool()
+	switch {
+	case err != nil:
+		results = append(results, doctor.Failed("enclave CPU pool", "%v", err))
+		return results
+	case pool.IsEmpty():
+		results = append(results, doctor.Failed("enclave CPU pool", "no CPUs are available to enclaves"))
+		return results
+	case necdp.PoolMismatch() != nil:
+		results = append(results, doctor.Warned("enclave CPU pool", "%d CPUs (%v) are available to enclaves, but %v", pool.Size(), pool, necdp.PoolMismatch()))
+	default:
+		results = append(results, doctor.Passed("enclave CPU pool", "%d CPUs (%v) are available to enclaves", pool.Size(), pool))
+	}
+
+	// enclaves get whole cores, so a CPU without its siblings can't be used
+	necdp.updatePool(pool)
+	unknown, split := cpulist.New(), cpulist.New()
+	for _, cpu := range pool.List() {
+		switch t := necdp.topology[cpu]; {
+		case !t.known:
+			unknown = unknown.Union(cpulist.New(cpu))
+		case !t.siblings.IsSubsetOf(pool):
+			split = split.Union(cpulist.New(cpu))
+		}
+	}
+	switch {
+	case !unknown.IsEmpty():
+		results = append(results, doctor.Warned("CPU siblings", "hyperthread siblings of CPUs %v are unknown, whole cores can't be preferred", unknown))
+	case !split.IsEmpty():
+		results = append(results, doctor.Warned("CPU siblings", "CPUs %v are available to enclaves without all of their hyperthread siblings", split))
+	default:
+		results = append(results, doctor.Passed("CPU siblings", "the enclave CPU pool consists of whole cores"))
+	}
+
+	return results
+}
@@ -0,0 +1,135 @@
+// Copyright 2026 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package nitro_enclaves_cpu_plugin
+
+import (
+	"k8s-ne-device-plugin/pkg/config"
+	"k8s-ne-device-plugin/pkg/fake_kubelet"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+)
+
+const e2eTimeout = 10 * time.Second
+
+// writeFakeSysRoot creates a sysfs root with the CPUs of writeFakeCPUSysfs, all of them but the
+// core of CPU 0 offline, and the given nitro_enclaves driver CPU pool.
+func writeFakeSysRoot(t *testing.T, neCPUs string) string {
+	t.Helper()
+	sysRoot := t.TempDir()
+	cpuDir := filepath.Join(sysRoot, deviceCPUSysfsPath)
+	if err := os.MkdirAll(filepath.Dir(cpuDir), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(writeFakeCPUSysfs(t, "1-3,5-7"), cpuDir); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Dir(filepath.Join(sysRoot, neCPUsParamPath)), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(sysRoot, neCPUsParamPath), []byte(neCPUs), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return sysRoot
+}
+
+// The plugin registers with the kubelet, advertises the enclave CPU pool read from sysfs,
+// prefers whole cores and streams changes of the pool.
+func TestEndToEnd(t *testing.T) {
+	sysRoot := writeFakeSysRoot(t, "1-3,5-7\n")
+
+	kubelet := fake_kubelet.New(t.TempDir())
+	if err := kubelet.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer kubelet.Stop()
+
+	cfg := &config.PluginConfig{
+		MaxEnclavesPerNode:      4,
+		EnclaveCPUAdvertisement: true,
+		AllocatorConfigPath:     filepath.Join(t.TempDir(), "allocator.yaml"),
+		DevicePluginDir:         kubelet.Dir(),
+		SysRoot:                 sysRoot,
+	}
+	p := NewNitroEnclavesCPUDevicePlugin(cfg)
+	if err := p.Start(); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	defer p.Stop()
+
+	req, err := kubelet.WaitForRegistration("aws.ec2.nitro/nitro_enclaves_cpus", e2eTimeout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.Endpoint != "nitro_enclaves_cpus.sock" || !req.Options.GetPreferredAllocationAvailable {
+		t.Errorf("Unexpected registration %v", req)
+	}
+
+	client, err := kubelet.Dial(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), e2eTimeout)
+	defer cancel()
+	stream, err := client.Watch(ctx)
+	if err != nil {
+		t.Fatalf("ListAndWatch() error = %v", err)
+	}
+	devs, err := stream.Next(e2eTimeout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := []string{}
+	for _, d := range devs {
+		ids = append(ids, d.ID)
+	}
+	if want := []string{"cpu_1", "cpu_2", "cpu_3", "cpu_5", "cpu_6", "cpu_7"}; !reflect.DeepEqual(ids, want) {
+		t.Fatalf("ListAndWatch() = %v, want %v", ids, want)
+	}
+
+	preferred, err := client.GetPreferredAllocation(ctx, &pluginapi.PreferredAllocationRequest{
+		ContainerRequests: []*pluginapi.ContainerPreferredAllocationRequest{
+			{AvailableDeviceIDs: ids, AllocationSize: 2},
+		},
+	})
+	if err != nil {
+		t.Fatalf("GetPreferredAllocation() error = %v", err)
+	}
+	if got := preferred.ContainerResponses[0].DeviceIDs; !reflect.DeepEqual(got, []string{"cpu_1", "cpu_5"}) {
+		t.Errorf("GetPreferredAllocation() = %v, want the whole core [cpu_1 cpu_5]", got)
+	}
+
+	resp, err := client.Allocate(ctx, &pluginapi.AllocateRequest{
+		ContainerRequests: []*pluginapi.ContainerAllocateRequest{{DevicesIDs: []string{"cpu_1", "cpu_5"}}},
+	})
+	if err != nil {
+		t.Fatalf("Allocate() error = %v", err)
+	}
+	if envs := resp.ContainerResponses[0].Envs; envs[enclaveCPUsEnv] != "2" || envs[enclaveCPUIDsEnv] != "1,5" {
+		t.Errorf("Allocate() envs = %v", envs)
+	}
+
+	// CPU 5 leaves the driver pool.
+	if err = os.WriteFile(filepath.Join(sysRoot, neCPUsParamPath), []byte("1-3,6-7\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	p.Reconfigure(cfg)
+	if _, err = stream.NextMatching(func(devs []*pluginapi.Device) bool {
+		for _, d := range devs {
+			if d.ID == "cpu_5" {
+				return d.Health == pluginapi.Unhealthy
+			}
+		}
+		return false
+	}, e2eTimeout); err != nil {
+		t.Fatalf("Expected cpu_5 to become unhealthy: %v", err)
+	}
+}
@@ -0,0 +1,304 @@
+// Copyright 2026 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package nitro_enclaves_cpu_plugin
+
+import (
+	"k8s-ne-device-plugin/pkg/cpulist"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/golang/glog"
+	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+)
+
+// cpuTopology describes the physical core and NUMA node a CPU (hyperthread) belongs to.
+type cpuTopology struct {
+	known bool
+	pkg   int
+	// siblings are the hyperthreads of the core, including the CPU itself.
+	siblings cpulist.CPUSet
+	// numaNode is -1 if the NUMA node of the CPU is unknown.
+	numaNode int
+}
+
+// coreKey identifies a physical core by its package and its lowest CPU. CPUs of unknown
+// topology are treated as a core of their own.
+type coreKey struct {
+	pkg int
+	cpu int
+}
+
+func (t cpuTopology) key(cpu int) coreKey {
+	if !t.known {
+		return coreKey{pkg: -1, cpu: cpu}
+	}
+	return coreKey{pkg: t.pkg, cpu: t.siblings.List()[0]}
+}
+
+func readSysfsInt(path string) (int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(strings.TrimSpace(string(data)))
+}
+
+// readCPUTopology reads the core and NUMA topology of a CPU. The core of an offline CPU is
+// unknown, as the kernel removes its topology, see lookupCPUTopology.
+func readCPUTopology(cpuSysfsPath, nodeSysfsPath string, cpu int) cpuTopology {
+	t, err := readCoreTopology(cpuSysfsPath, cpu)
+	if err != nil {
+		glog.V(1).Infof("Unable to read the core of CPU %d: %v", cpu, err)
+	}
+	t.numaNode = readNUMANode(cpuSysfsPath, nodeSysfsPath, cpu)
+	return t
+}
+
+// lookupCPUTopology returns the topology of a CPU read from sysfs or, for offline CPUs, inferred
+// from the cores in topology, see inferCoreTopology.
+func lookupCPUTopology(cpuSysfsPath, nodeSysfsPath string, cpu int, topology map[int]cpuTopology) cpuTopology {
+	t := readCPUTopology(cpuSysfsPath, nodeSysfsPath, cpu)
+	if t.known {
+		return t
+	}
+	if core, ok := inferCoreTopology(cpu, t.numaNode, topology); ok {
+		return core
+	}
+	return t
+}
+
+// inferCoreTopology derives the core of an offline CPU from the cores in topology. The allocator
+// service only reserves whole cores, and EC2 instances number the first threads of all cores
+// first, so core c runs the CPUs c, c+stride, c+2*stride, ... with the same stride and number of
+// threads on every core. The layout is taken from the cores with the most threads, as the
+// siblings of online CPUs don't list offline ones. The package is taken from a CPU on the same
+// NUMA node. Returns false if topology holds no core or cores of different layouts.
+func inferCoreTopology(cpu, numaNode int, topology map[int]cpuTopology) (cpuTopology, bool) {
+	stride, threads, pkg := 0, 0, numaNode
+	for _, t := range topology {
+		if !t.known {
+			continue
+		}
+		if t.numaNode == numaNode {
+			pkg = t.pkg
+		}
+
+		cpus := t.siblings.List()
+		step := 0
+		if len(cpus) > 1 {
+			step = cpus[1] - cpus[0]
+		}
+		for i := 2; i < len(cpus); i++ {
+			if cpus[i]-cpus[i-1] != step {
+				return cpuTopology{}, false
+			}
+		}
+		switch {
+		case len(cpus) > threads:
+			stride, threads = step, len(cpus)
+		case len(cpus) == threads && step != stride:
+			return cpuTopology{}, false
+		}
+	}
+	if threads == 0 {
+		return cpuTopology{}, false
+	}
+	if threads == 1 {
+		return cpuTopology{known: true, pkg: pkg, siblings: cpulist.New(cpu), numaNode: numaNode}, true
+	}
+	if cpu >= stride*threads {
+		return cpuTopology{}, false
+	}
+
+	siblings := make([]int, 0, threads)
+	for thread := 0; thread < threads; thread++ {
+		siblings = append(siblings, cpu%stride+thread*stride)
+	}
+	return cpuTopology{known: true, pkg: pkg, siblings: cpulist.New(siblings...), numaNode: numaNode}, true
+}
+
+// readNUMANode determines the NUMA node of a CPU from the <cpuSysfsPath>/cpuN/nodeX link or,
+// if not present, from the <nodeSysfsPath>/nodeX/cpulist files. Returns -1 if unknown.
+func readNUMANode(cpuSysfsPath, nodeSysfsPath string, cpu int) int {
+	links, _ := filepath.Glob(filepath.Join(cpuSysfsPath, "cpu"+strconv.Itoa(cpu), "node*"))
+	for _, link := range links {
+		if node, err := strconv.Atoi(strings.TrimPrefix(filepath.Base(link), "node")); err == nil {
+			return node
+		}
+	}
+
+	nodes, _ := filepath.Glob(filepath.Join(nodeSysfsPath, "node*"))
+	for _, dir := range nodes {
+		node, err := strconv.Atoi(strings.TrimPrefix(filepath.Base(dir), "node"))
+		if err != nil {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, "cpulist"))
+		if err != nil {
+			continue
+		}
+		if cpus, err := cpulist.Parse(string(data)); err == nil && cpus.Contains(cpu) {
+			return node
+		}
+	}
+
+	glog.V(1).Infof("Unable to determine NUMA node of CPU %d", cpu)
+	return -1
+}
+
+// topologyInfo returns the NUMA topology to advertise with the CPU device, if known.
+func (t cpuTopology) topologyInfo() *pluginapi.TopologyInfo {
+	if t.numaNode < 0 {
+		return nil
+	}
+	return &pluginapi.TopologyInfo{
+		Nodes: []*pluginapi.NUMANode{{ID: int64(t.numaNode)}},
+	}
+}
+
+// readCoreTopology reads package and hyperthread siblings of a CPU from
+// <cpuSysfsPath>/cpuN/topology. If missing, the CPU is considered a core of its own.
+func readCoreTopology(cpuSysfsPath string, cpu int) (cpuTopology, error) {
+	dir := filepath.Join(cpuSysfsPath, "cpu"+strconv.Itoa(cpu), "topology")
+	unknown := cpuTopology{siblings: cpulist.New(cpu)}
+
+	pkg, err := readSysfsInt(filepath.Join(dir, "physical_package_id"))
+	if err != nil {
+		return unknown, err
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "thread_siblings_list"))
+	if err != nil {
+		return unknown, err
+	}
+	siblings, err := cpulist.Parse(string(data))
+	if err != nil {
+		return unknown, err
+	}
+
+	return cpuTopology{known: true, pkg: pkg, siblings: siblings.Union(cpulist.New(cpu))}, nil
+}
+
+// preferCPUs picks size CPUs out of available, always including mustInclude. Siblings of
+// already selected CPUs are taken first, then complete sibling groups (whole physical cores)
+// and only then single hyperthreads. Packages already holding selected CPUs are preferred,
+// followed by the package which fits the remaining request most tightly.
+func preferCPUs(available, mustInclude cpulist.CPUSet, size int, topology map[int]cpuTopology) cpulist.CPUSet {
+	selected := mustInclude
+	free := available.Difference(selected)
+	if selected.Size() >= size || free.IsEmpty() {
+		return selected
+	}
+
+	topologyOf := func(cpu int) cpuTopology {
+		if t, ok := topology[cpu]; ok {
+			return t
+		}
+		return cpuTopology{siblings: cpulist.New(cpu)}
+	}
+
+	// Group free CPUs per physical core.
+	cores := map[coreKey][]int{}
+	for _, cpu := range free.List() {
+		k := topologyOf(cpu).key(cpu)
+		cores[k] = append(cores[k], cpu)
+	}
+
+	selectedPkgs := map[int]bool{}
+	selectedCores := map[coreKey]bool{}
+	for _, cpu := range selected.List() {
+		t := topologyOf(cpu)
+		selectedPkgs[t.pkg] = true
+		selectedCores[t.key(cpu)] = true
+	}
+
+	// A core is complete if all its hyperthreads are free.
+	complete := map[coreKey]bool{}
+	capacity := map[int]int{}
+	for k, cpus := range cores {
+		if topologyOf(cpus[0]).siblings.IsSubsetOf(free) {
+			complete[k] = true
+			capacity[k.pkg] += len(cpus)
+		}
+	}
+
+	remaining := size - selected.Size()
+	pkgBefore := func(a, b int) bool {
+		if selectedPkgs[a] != selectedPkgs[b] {
+			return selectedPkgs[a]
+		}
+		fitsA, fitsB := capacity[a] >= remaining, capacity[b] >= remaining
+		if fitsA != fitsB {
+			return fitsA
+		}
+		if capacity[a] != capacity[b] {
+			if fitsA {
+				return capacity[a] < capacity[b]
+			}
+			return capacity[a] > capacity[b]
+		}
+		return a < b
+	}
+
+	rank := func(k coreKey) int {
+		switch {
+		case selectedCores[k]:
+			return 0
+		case complete[k]:
+			return 1
+		default:
+			return 2
+		}
+	}
+
+	keys := make([]coreKey, 0, len(cores))
+	for k := range cores {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := keys[i], keys[j]
+		if rank(a) != rank(b) {
+			return rank(a) < rank(b)
+		}
+		if a.pkg != b.pkg {
+			return pkgBefore(a.pkg, b.pkg)
+		}
+		return cores[a][0] < cores[b][0]
+	})
+
+	picked := selected.List()
+	taken := map[coreKey]bool{}
+	// First pass: partially selected cores and whole cores that still fit.
+	for _, k := range keys {
+		if remaining == 0 {
+			break
+		}
+		if rank(k) == 0 {
+			n := min(len(cores[k]), remaining)
+			picked = append(picked, cores[k][:n]...)
+			remaining -= n
+			taken[k] = true
+		} else if len(cores[k]) <= remaining {
+			picked = append(picked, cores[k]...)
+			remaining -= len(cores[k])
+			taken[k] = true
+		}
+	}
+	// Second pass: fill up with single hyperthreads.
+	for _, k := range keys {
+		if remaining == 0 {
+			break
+		}
+		if taken[k] {
+			continue
+		}
+		n := min(len(cores[k]), remaining)
+		picked = append(picked, cores[k][:n]...)
+		remaining -= n
+	}
+
+	return cpulist.New(picked...)
+}
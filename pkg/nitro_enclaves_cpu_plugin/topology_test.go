@@ -0,0 +1,240 @@
+// Copyright 2026 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package nitro_enclaves_cpu_plugin
+
+import (
+	"k8s-ne-device-plugin/pkg/config"
+	"k8s-ne-device-plugin/pkg/cpulist"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/context"
+	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+)
+
+// writeFakeCPUSysfs creates a CPU sysfs tree with two packages of two cores, each core
+// running two hyperthreads: package 0 holds cores {0,4} and {1,5}, package 1 holds {2,6} and {3,7}.
+// Each package is its own NUMA node. Like the kernel, it has no topology for offline CPUs and
+// doesn't list them as siblings of online ones.
+func writeFakeCPUSysfs(t *testing.T, offline string) string {
+	t.Helper()
+	offlineCPUs, err := cpulist.Parse(offline)
+	if err != nil {
+		t.Fatal(err)
+	}
+	online := cpulist.New(0, 1, 2, 3, 4, 5, 6, 7).Difference(offlineCPUs)
+
+	sysfs := t.TempDir()
+	for cpu := 0; cpu < 8; cpu++ {
+		core := cpu % 4
+		node := filepath.Join(sysfs, "cpu"+strconv.Itoa(cpu), "node"+strconv.Itoa(core/2))
+		if err := os.MkdirAll(node, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if !online.Contains(cpu) {
+			continue
+		}
+
+		dir := filepath.Join(sysfs, "cpu"+strconv.Itoa(cpu), "topology")
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		files := map[string]string{
+			"core_id":              strconv.Itoa(core % 2),
+			"physical_package_id":  strconv.Itoa(core / 2),
+			"thread_siblings_list": cpulist.New(core, core+4).Intersection(online).String() + "\n",
+		}
+		for name, content := range files {
+			if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	for name, cpus := range map[string]cpulist.CPUSet{offlineCPUsFile: offlineCPUs, onlineCPUsFile: online} {
+		if err := os.WriteFile(filepath.Join(sysfs, name), []byte(cpus.String()+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return sysfs
+}
+
+func TestReadCPUTopology(t *testing.T) {
+	sysfs := writeFakeCPUSysfs(t, "")
+
+	got := readCPUTopology(sysfs, t.TempDir(), 6)
+	if !got.known || got.pkg != 1 || got.siblings.String() != "2,6" || got.numaNode != 1 {
+		t.Errorf("readCPUTopology() = %+v, want package 1, siblings 2,6, NUMA node 1", got)
+	}
+
+	got = readCPUTopology(sysfs, t.TempDir(), 8)
+	if got.known || got.siblings.String() != "8" || got.numaNode != -1 || got.topologyInfo() != nil {
+		t.Errorf("readCPUTopology() = %+v, want unknown topology with CPU 8 as its only sibling", got)
+	}
+}
+
+// The cores of offline CPUs, whose topology the kernel removed, are inferred from the online cores.
+func TestLookupCPUTopologyOfOfflineCPUs(t *testing.T) {
+	sysfs := writeFakeCPUSysfs(t, "1-3,5-7")
+	online := map[int]cpuTopology{}
+	for _, cpu := range []int{0, 4} {
+		online[cpu] = readCPUTopology(sysfs, t.TempDir(), cpu)
+	}
+
+	if got := readCPUTopology(sysfs, t.TempDir(), 6); got.known {
+		t.Errorf("readCPUTopology() = %+v, want unknown topology of an offline CPU", got)
+	}
+	got := lookupCPUTopology(sysfs, t.TempDir(), 6, online)
+	if !got.known || got.siblings.String() != "2,6" || got.numaNode != 1 {
+		t.Errorf("lookupCPUTopology() = %+v, want siblings 2,6 on NUMA node 1", got)
+	}
+
+	if got = lookupCPUTopology(sysfs, t.TempDir(), 6, map[int]cpuTopology{}); got.known {
+		t.Errorf("lookupCPUTopology() = %+v, want unknown topology without online cores", got)
+	}
+	if got = lookupCPUTopology(sysfs, t.TempDir(), 9, online); got.known {
+		t.Errorf("lookupCPUTopology() = %+v, want unknown topology of a CPU beyond the cores", got)
+	}
+
+	// without SMT every CPU is a core of its own
+	single := map[int]cpuTopology{0: {known: true, siblings: cpulist.New(0), numaNode: -1}}
+	if got = lookupCPUTopology(sysfs, t.TempDir(), 6, single); !got.known || got.siblings.String() != "6" {
+		t.Errorf("lookupCPUTopology() = %+v, want CPU 6 as its only sibling", got)
+	}
+}
+
+// Without a nodeX link in the CPU directory, the NUMA node is looked up in the node cpulists.
+func TestReadNUMANodeFromNodeCPUList(t *testing.T) {
+	nodes := t.TempDir()
+	for node, cpus := range map[string]string{"node0": "0-3\n", "node1": "4-7\n"} {
+		if err := os.MkdirAll(filepath.Join(nodes, node), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(nodes, node, "cpulist"), []byte(cpus), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if got := readNUMANode(t.TempDir(), nodes, 5); got != 1 {
+		t.Errorf("readNUMANode() = %d, want 1", got)
+	}
+	if got := readNUMANode(t.TempDir(), nodes, 9); got != -1 {
+		t.Errorf("readNUMANode() = %d, want -1", got)
+	}
+}
+
+func TestPreferCPUs(t *testing.T) {
+	sysfs := writeFakeCPUSysfs(t, "")
+	topology := map[int]cpuTopology{}
+	for cpu := 0; cpu < 8; cpu++ {
+		topology[cpu] = readCPUTopology(sysfs, t.TempDir(), cpu)
+	}
+
+	tests := []struct {
+		name        string
+		available   string
+		mustInclude string
+		size        int
+		want        string
+	}{
+		{name: "single core", available: "0-7", size: 2, want: "0,4"},
+		{name: "two cores on the same package", available: "0-7", size: 4, want: "0-1,4-5"},
+		{name: "tightest package", available: "0-3,5-7", size: 2, want: "1,5"},
+		{name: "complete the core of a must include CPU", available: "0-7", mustInclude: "2", size: 2, want: "2,6"},
+		{name: "stay on the package of a must include CPU", available: "0-7", mustInclude: "3,7", size: 4, want: "2-3,6-7"},
+		{name: "odd size splits a single core", available: "0-7", size: 3, want: "0-1,4"},
+		{name: "broken cores only", available: "0-3", size: 2, want: "0-1"},
+		{name: "not enough CPUs", available: "0,4", size: 4, want: "0,4"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			available, _ := cpulist.Parse(tt.available)
+			mustInclude, _ := cpulist.Parse(tt.mustInclude)
+			got := preferCPUs(available, mustInclude, tt.size, topology)
+			if got.String() != tt.want {
+				t.Errorf("preferCPUs() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetPreferredAllocation(t *testing.T) {
+	p := NewNitroEnclavesCPUDevicePlugin(&config.PluginConfig{MaxEnclavesPerNode: 4})
+	p.cpuSysfsPath = writeFakeCPUSysfs(t, "1-3,5-7")
+	p.neCPUsPath = filepath.Join(p.cpuSysfsPath, "ne_cpus")
+	p.refresh()
+
+	if len(p.Devices()) != 6 {
+		t.Fatalf("Expected the 6 offline CPUs to be advertised but got %v", p.Devices())
+	}
+	for _, d := range p.Devices() {
+		cpu, _ := parseEnclaveCPUID(d.ID)
+		if d.Topology == nil || d.Topology.Nodes[0].ID != int64(cpu%4/2) {
+			t.Errorf("Expected %s to be on NUMA node %d but got %v", d.ID, cpu%4/2, d.Topology)
+		}
+	}
+
+	opts, _ := p.GetDevicePluginOptions(context.Background(), &pluginapi.Empty{})
+	if !opts.GetPreferredAllocationAvailable {
+		t.Fatal("Expected GetPreferredAllocationAvailable to be advertised")
+	}
+
+	resp, err := p.GetPreferredAllocation(context.Background(), &pluginapi.PreferredAllocationRequest{
+		ContainerRequests: []*pluginapi.ContainerPreferredAllocationRequest{
+			{
+				AvailableDeviceIDs:   []string{"cpu_1", "cpu_2", "cpu_3", "cpu_5", "cpu_6", "cpu_7"},
+				MustIncludeDeviceIDs: []string{"cpu_3"},
+				AllocationSize:       2,
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("GetPreferredAllocation() error = %v", err)
+	}
+
+	want := []string{"cpu_3", "cpu_7"}
+	if got := resp.ContainerResponses[0].DeviceIDs; !reflect.DeepEqual(got, want) {
+		t.Errorf("GetPreferredAllocation() = %v, want %v", got, want)
+	}
+}
+
+// Diagnose warns about enclave CPUs whose hyperthread siblings are not part of the pool.
+func TestDiagnoseSplitCores(t *testing.T) {
+	sysRoot := writeFakeSysRoot(t, "1-3,6-7\n")
+	results := Diagnose(&config.PluginConfig{SysRoot: sysRoot, AllocatorConfigPath: filepath.Join(t.TempDir(), "allocator.yaml")})
+
+	statuses := map[string]string{}
+	for _, result := range results {
+		statuses[result.Check] = string(result.Status) + ": " + result.Message
+	}
+	if got := statuses["CPU siblings"]; got != "warn: CPUs 1 are available to enclaves without all of their hyperthread siblings" {
+		t.Errorf("CPU siblings check = %q", got)
+	}
+	if got := statuses["enclave CPU pool"]; !strings.HasPrefix(got, "warn: 5 CPUs (1-3,6-7)") {
+		t.Errorf("enclave CPU pool check = %q", got)
+	}
+}
+
+// Diagnose warns about enclave CPUs whose hyperthread siblings can't be determined.
+func TestDiagnoseUnknownSiblings(t *testing.T) {
+	sysRoot := writeFakeSysRoot(t, "1-3,5-7\n")
+	for _, cpu := range []string{"cpu0", "cpu4"} {
+		if err := os.RemoveAll(filepath.Join(sysRoot, deviceCPUSysfsPath, cpu, "topology")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	results := Diagnose(&config.PluginConfig{SysRoot: sysRoot, AllocatorConfigPath: filepath.Join(t.TempDir(), "allocator.yaml")})
+
+	statuses := map[string]string{}
+	for _, result := range results {
+		statuses[result.Check] = string(result.Status) + ": " + result.Message
+	}
+	if got := statuses["CPU siblings"]; got != "warn: hyperthread siblings of CPUs 1-3,5-7 are unknown, whole cores can't be preferred" {
+		t.Errorf("CPU siblings check = %q", got)
+	}
+}
@@ -0,0 +1,81 @@
+// Copyright 2026 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package nitro_enclaves_cpu_plugin
+
+import (
+	"bytes"
+	"errors"
+	"syscall"
+
+	"github.com/golang/glog"
+)
+
+const (
+	ueventBufferSize   = 8192
+	ueventPollInterval = 1 // seconds
+)
+
+// isCPUHotplugUevent reports whether a raw kernel uevent ("ACTION@DEVPATH\0KEY=VALUE\0...")
+// belongs to the cpu subsystem.
+func isCPUHotplugUevent(msg []byte) bool {
+	for _, field := range bytes.Split(msg, []byte{0}) {
+		if bytes.Equal(field, []byte("SUBSYSTEM=cpu")) {
+			return true
+		}
+	}
+	return false
+}
+
+// watchCPUHotplugEvents listens for kernel uevents and signals on the returned channel
+// whenever a CPU is added, removed, onlined or offlined.
+func watchCPUHotplugEvents(stop <-chan interface{}) (<-chan struct{}, error) {
+	fd, err := syscall.Socket(syscall.AF_NETLINK, syscall.SOCK_DGRAM|syscall.SOCK_CLOEXEC, syscall.NETLINK_KOBJECT_UEVENT)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = syscall.Bind(fd, &syscall.SockaddrNetlink{Family: syscall.AF_NETLINK, Groups: 1}); err != nil {
+		syscall.Close(fd)
+		return nil, err
+	}
+
+	// A receive timeout lets the listener notice the stop channel.
+	timeout := syscall.Timeval{Sec: ueventPollInterval}
+	if err = syscall.SetsockoptTimeval(fd, syscall.SOL_SOCKET, syscall.SO_RCVTIMEO, &timeout); err != nil {
+		syscall.Close(fd)
+		return nil, err
+	}
+
+	events := make(chan struct{}, 1)
+	go func() {
+		defer syscall.Close(fd)
+
+		buf := make([]byte, ueventBufferSize)
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+
+			n, _, err := syscall.Recvfrom(fd, buf, 0)
+			if err != nil {
+				if errors.Is(err, syscall.EAGAIN) || errors.Is(err, syscall.EINTR) {
+					continue
+				}
+				glog.Errorf("Error while receiving CPU hotplug events: %v", err)
+				return
+			}
+
+			if isCPUHotplugUevent(buf[:n]) {
+				select {
+				case events <- struct{}{}:
+				default:
+				}
+			}
+		}
+	}()
+
+	return events, nil
+}
@@ -0,0 +1,13 @@
+// Copyright 2026 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build !linux
+
+package nitro_enclaves_cpu_plugin
+
+import "errors"
+
+// watchCPUHotplugEvents is only supported on Linux, other platforms rely on polling.
+func watchCPUHotplugEvents(stop <-chan interface{}) (<-chan struct{}, error) {
+	return nil, errors.New("CPU hotplug uevents are only supported on Linux")
+}
@@ -0,0 +1,178 @@
+// Copyright 2026 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package nitro_enclaves_device_monitor
+
+import (
+	"context"
+	"errors"
+	"k8s-ne-device-plugin/pkg/config"
+	"k8s-ne-device-plugin/pkg/fake_kubelet"
+	"k8s-ne-device-plugin/pkg/nitro_enclaves_device_plugin"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+)
+
+const (
+	e2eTimeout   = 10 * time.Second
+	resourceName = "aws.ec2.nitro/nitro_enclaves"
+)
+
+// startSupervisor runs a supervisor with the enclave plugin against kubelet until the test ends.
+func startSupervisor(t *testing.T, kubelet *fake_kubelet.Kubelet) *NitroEnclavesPluginMonitor {
+	t.Helper()
+	devDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(devDir, "nitro_enclaves"), nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	plugin := nitro_enclaves_device_plugin.NewNitroEnclavesDevicePlugin(&config.PluginConfig{
+		MaxEnclavesPerNode: 1,
+		DevicePluginDir:    kubelet.Dir(),
+		DevRoot:            devDir,
+	})
+
+	nes := NewNitroEnclavesSupervisor(kubelet.Dir())
+	monitor := nes.Add(plugin)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- nes.Run(ctx)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		if err := <-done; err != nil {
+			t.Errorf("Run() error = %v", err)
+		}
+	})
+	return monitor
+}
+
+// watchUntilReady acts like the kubelet after a registration: it opens a ListAndWatch stream
+// to the registered plugin and consumes the device lists until the monitor is ready. As a
+// restarted plugin ends the stream and registers again, the stream is re-opened whenever it
+// ends.
+func watchUntilReady(t *testing.T, kubelet *fake_kubelet.Kubelet, monitor *NitroEnclavesPluginMonitor) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), e2eTimeout)
+	t.Cleanup(cancel)
+
+	for ctx.Err() == nil {
+		req, err := kubelet.WaitForRegistration(resourceName, e2eTimeout)
+		if err != nil {
+			t.Fatal(err)
+		}
+		client, err := kubelet.Dial(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { client.Close() })
+
+		stream, err := client.Watch(ctx)
+		if err != nil {
+			continue
+		}
+		for {
+			if _, err = stream.Next(50 * time.Millisecond); errors.Is(err, fake_kubelet.ErrStreamEnded) {
+				break
+			}
+			if monitor.Ready() == nil {
+				return
+			}
+		}
+	}
+	t.Fatalf("The %v monitor did not become ready: %v", monitor.ResourceName(), monitor.Ready())
+}
+
+// waitForReadiness waits until ready accepts the readiness of monitor.
+func waitForReadiness(t *testing.T, monitor *NitroEnclavesPluginMonitor, ready func(error) bool) {
+	t.Helper()
+	var err error
+	for deadline := time.Now().Add(e2eTimeout); time.Now().Before(deadline); time.Sleep(50 * time.Millisecond) {
+		if err = monitor.Ready(); ready(err) {
+			return
+		}
+	}
+	t.Fatalf("Unexpected readiness of the %v monitor: %v", monitor.ResourceName(), err)
+}
+
+// The supervised plugin registers, becomes ready once the kubelet streams its devices and
+// registers again after a kubelet restart wiped its socket.
+func TestSupervisorEndToEnd(t *testing.T) {
+	kubelet := fake_kubelet.New(t.TempDir())
+	if err := kubelet.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer kubelet.Stop()
+
+	monitor := startSupervisor(t, kubelet)
+	watchUntilReady(t, kubelet, monitor)
+
+	// the status reflects the registration, the stream and the allocations of the kubelet
+	req, err := kubelet.WaitForRegistration(resourceName, e2eTimeout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, err := kubelet.Dial(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	devices := monitor.Status().Devices
+	if len(devices) != 1 {
+		t.Fatalf("Status().Devices = %+v, want a single device", devices)
+	}
+	if _, err = client.Allocate(context.Background(), &pluginapi.AllocateRequest{
+		ContainerRequests: []*pluginapi.ContainerAllocateRequest{{DevicesIDs: []string{devices[0].ID}}},
+	}); err != nil {
+		t.Fatalf("Allocate() error = %v", err)
+	}
+	status := monitor.Status()
+	if status.State != "Running" || !status.Registered || status.LastRegistration == nil || status.LastRegistration.Error != "" {
+		t.Errorf("Status() = %+v, want a running and registered plugin", status)
+	}
+	if status.ListAndWatchStreams == 0 {
+		t.Errorf("Status() = %+v, want an active stream", status)
+	}
+	if len(status.RecentAllocations) != 1 || status.RecentAllocations[0].DeviceIDs[0] != devices[0].ID {
+		t.Errorf("Status().RecentAllocations = %+v, want the allocation of %v", status.RecentAllocations, devices[0].ID)
+	}
+
+	if err = kubelet.Restart(); err != nil {
+		t.Fatal(err)
+	}
+	watchUntilReady(t, kubelet, monitor)
+}
+
+// A plugin rejected by the kubelet is reported as such and retried until it is accepted.
+func TestSupervisorEndToEndRejected(t *testing.T) {
+	kubelet := fake_kubelet.New(t.TempDir())
+	kubelet.Reject(errors.New("resource is not allowed"))
+	if err := kubelet.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer kubelet.Stop()
+
+	monitor := startSupervisor(t, kubelet)
+	waitForReadiness(t, monitor, func(err error) bool {
+		return err != nil && strings.Contains(err.Error(), "Rejected") && strings.Contains(err.Error(), "resource is not allowed")
+	})
+	if requests := kubelet.Requests(); len(requests) != 0 {
+		t.Fatalf("Expected no accepted registration but got %v", requests)
+	}
+	if status := monitor.Status(); status.Registered || status.LastRegistration == nil || !strings.Contains(status.LastRegistration.Error, "resource is not allowed") {
+		t.Errorf("Status() = %+v, want the rejected registration", status)
+	}
+
+	kubelet.Reject(nil)
+	watchUntilReady(t, kubelet, monitor)
+
+	if req := kubelet.Requests()[0]; req.Version != pluginapi.Version {
+		t.Errorf("Registered with version %v, want %v", req.Version, pluginapi.Version)
+	}
+}
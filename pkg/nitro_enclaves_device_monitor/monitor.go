@@ -4,14 +4,19 @@
 package nitro_enclaves_device_monitor
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net"
 	"os"
-	"os/signal"
-	"syscall"
+	"sync"
 	"time"
 
-	"github.com/fsnotify/fsnotify"
 	"github.com/golang/glog"
-	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+	"k8s-ne-device-plugin/pkg/device_plugin_framework"
+	"k8s-ne-device-plugin/pkg/introspection"
+	"k8s-ne-device-plugin/pkg/metrics"
 )
 
 type PluginState int
@@ -20,8 +25,30 @@ const (
 	PluginIdle       PluginState = 0
 	PluginRunning    PluginState = 1
 	PluginRestarting PluginState = 2
+	// PluginWaitingForKubelet means that the plugin could not register because the kubelet
+	// is not up (yet) or did not answer in time.
+	PluginWaitingForKubelet PluginState = 3
+	// PluginRejected means that the kubelet refused the registration of the plugin.
+	PluginRejected PluginState = 4
 
-	pluginStartRetryTimeout = 3 * time.Second
+	// pluginStartInitialBackoff is the delay before the first retry of a failed plugin start.
+	pluginStartInitialBackoff = time.Second
+	// DefaultMaxStartBackoff is the default upper bound of the delay between plugin start retries.
+	DefaultMaxStartBackoff = time.Minute
+
+	// monitorHeartbeatInterval is the longest time the monitor loop waits for an event
+	// before proving that it is still alive.
+	monitorHeartbeatInterval = 10 * time.Second
+	// socketCheckTimeout bounds the connection attempt verifying that the plugin socket is served.
+	socketCheckTimeout = time.Second
+
+	// monitorStallTimeout is the time after which a monitor loop without heartbeat is
+	// considered stalled. It leaves room for a slow plugin start.
+	monitorStallTimeout = 6 * monitorHeartbeatInterval
+
+	// DefaultStartFailureThreshold is the number of consecutive plugin start failures after
+	// which the monitor is reported as not alive.
+	DefaultStartFailureThreshold = 10
 )
 
 type IPluginState interface {
@@ -29,13 +56,31 @@ type IPluginState interface {
 	setState(PluginState)
 }
 
+// NitroEnclavesPluginMonitor keeps a single device plugin running. It is driven by a
+// NitroEnclavesSupervisor, which owns the signal handler and the kubelet socket watcher.
 type NitroEnclavesPluginMonitor struct {
-	pluginState       PluginState
-	devicePlugin      IBasicDevicePlugin
-	fsWatcher         *fsnotify.Watcher
-	sigWatcher        chan os.Signal
-	devicePluginPath  string
-	kubeletSocketName string
+	pluginState  PluginState
+	devicePlugin IBasicDevicePlugin
+	// restart is signalled by the supervisor when the plugin needs to re-register.
+	restart chan struct{}
+	// socketCheck is signalled by the supervisor when the plugin socket may have been removed.
+	socketCheck chan struct{}
+
+	// StartFailureThreshold is the number of consecutive start failures after which Live
+	// fails. Zero disables the check. Use SetStartLimits once the monitor runs.
+	StartFailureThreshold int
+	// MaxStartBackoff caps the exponentially growing delay between plugin start retries. Use
+	// SetStartLimits once the monitor runs.
+	MaxStartBackoff time.Duration
+
+	// mutex guards pluginState, lastHeartbeat, startFailures, lastStartError and stopped,
+	// which are read by the health probes, and the start limits.
+	mutex          sync.Mutex
+	lastHeartbeat  time.Time
+	startFailures  int
+	lastStartError error
+	stopped        bool
+
 	IPluginState
 }
 
@@ -47,87 +92,233 @@ func (ps PluginState) String() string {
 		return "Restarting"
 	case PluginRunning:
 		return "Running"
+	case PluginWaitingForKubelet:
+		return "WaitingForKubelet"
+	case PluginRejected:
+		return "Rejected"
 	default:
 		return "Unknown"
 	}
 }
 
 func (nepm *NitroEnclavesPluginMonitor) state() PluginState {
+	nepm.mutex.Lock()
+	defer nepm.mutex.Unlock()
 	return nepm.pluginState
 }
 
 func (nepm *NitroEnclavesPluginMonitor) setState(newState PluginState) {
+	nepm.mutex.Lock()
 	nepm.pluginState = newState
+	nepm.mutex.Unlock()
+	metrics.SetPluginState(nepm.devicePlugin.ResourceName(), int(newState))
 }
 
-func (nepm *NitroEnclavesPluginMonitor) Init() error {
-	glog.V(0).Infof("Creating plugin monitor for %v", nepm.devicePlugin.ResourceName())
-	nepm.setState(PluginIdle)
-
-	var err error
+// heartbeat records that the monitor loop is making progress.
+func (nepm *NitroEnclavesPluginMonitor) heartbeat() {
+	nepm.mutex.Lock()
+	defer nepm.mutex.Unlock()
+	nepm.lastHeartbeat = time.Now()
+}
 
-	if nepm.fsWatcher, err = fsnotify.NewWatcher(); err != nil {
-		glog.Error("Error while creating file system watcher!")
-		return err
+// recordStart records the outcome of a plugin start.
+func (nepm *NitroEnclavesPluginMonitor) recordStart(err error) {
+	nepm.mutex.Lock()
+	defer nepm.mutex.Unlock()
+	if err != nil {
+		nepm.startFailures++
+	} else {
+		nepm.startFailures = 0
 	}
+	nepm.lastStartError = err
+}
 
-	if err = nepm.fsWatcher.Add(nepm.devicePluginPath); err != nil {
-		glog.Errorf("Error while accessing: %s", pluginapi.DevicePluginPath)
-		defer nepm.fsWatcher.Close()
-		return err
+// startFailureState returns the state reflecting a failed plugin start. Failures which are not
+// related to the kubelet registration leave the current state untouched.
+func startFailureState(current PluginState, err error) PluginState {
+	switch {
+	case errors.Is(err, device_plugin_framework.ErrKubeletSocketMissing),
+		errors.Is(err, device_plugin_framework.ErrRegistrationTimeout):
+		return PluginWaitingForKubelet
+	case errors.Is(err, device_plugin_framework.ErrRegistrationRejected):
+		return PluginRejected
+	default:
+		return current
 	}
+}
 
-	nepm.sigWatcher = make(chan os.Signal, 1)
-	signal.Notify(nepm.sigWatcher, syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT)
-	glog.V(0).Info("Plugin monitor has been successfully created.")
+// SetStartLimits changes StartFailureThreshold and MaxStartBackoff, e.g. on a config reload,
+// taking effect with the next start attempt.
+func (nepm *NitroEnclavesPluginMonitor) SetStartLimits(startFailureThreshold int, maxStartBackoff time.Duration) {
+	nepm.mutex.Lock()
+	defer nepm.mutex.Unlock()
 
-	return nil
+	nepm.StartFailureThreshold = startFailureThreshold
+	nepm.MaxStartBackoff = maxStartBackoff
 }
 
-func run(nepm *NitroEnclavesPluginMonitor) bool {
-	cont := true
+// startBackoff returns the delay before the next start attempt. It doubles with every
+// consecutive failure up to MaxStartBackoff, and is randomized between half and the full
+// delay, so that plugins do not hit a restarting kubelet at the same time.
+func (nepm *NitroEnclavesPluginMonitor) startBackoff() time.Duration {
+	nepm.mutex.Lock()
+	failures, maxBackoff := nepm.startFailures, nepm.MaxStartBackoff
+	nepm.mutex.Unlock()
 
-	if nepm.state() != PluginRunning {
-		if err := nepm.devicePlugin.Start(); err != nil {
-			// Sleep and try again as long as the monitor is running.
-			time.Sleep(pluginStartRetryTimeout)
-			return cont
+	if maxBackoff <= 0 {
+		maxBackoff = DefaultMaxStartBackoff
+	}
+	backoff := pluginStartInitialBackoff
+	for i := 1; i < failures && backoff < maxBackoff; i++ {
+		backoff *= 2
+	}
+	backoff = min(backoff, maxBackoff)
+
+	return backoff/2 + rand.N(backoff/2+1)
+}
+
+// waitForRetry waits for the given delay, keeping the heartbeat up. It returns early with
+// true on a restart request, which means the kubelet is back, and with false once ctx is
+// cancelled.
+func (nepm *NitroEnclavesPluginMonitor) waitForRetry(ctx context.Context, delay time.Duration) bool {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	ticker := time.NewTicker(monitorHeartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-timer.C:
+			return true
+		case <-nepm.restart:
+			return true
+		case <-ticker.C:
+			nepm.heartbeat()
+		case <-ctx.Done():
+			return false
 		}
 	}
+}
 
-	nepm.setState(PluginRunning)
-	glog.V(0).Infof("%v plugin state is: %v.", nepm.devicePlugin.ResourceName(), nepm.state())
+// requestRestart asks the monitor to restart its plugin. Requests are coalesced until the
+// monitor handles them.
+func (nepm *NitroEnclavesPluginMonitor) requestRestart() {
+	select {
+	case nepm.restart <- struct{}{}:
+	default:
+	}
+}
 
-L:
+// requestSocketCheck asks the monitor to verify the plugin socket. Requests are coalesced
+// until the monitor handles them.
+func (nepm *NitroEnclavesPluginMonitor) requestSocketCheck() {
 	select {
-	case fsEvent := <-nepm.fsWatcher.Events:
-		//glog.V(0).Info("FS EVENT: ", fsEvent)
-		if fsEvent.Name == nepm.kubeletSocketName {
-			if fsEvent.Op&fsnotify.Create == fsnotify.Create {
-				glog.V(0).Infof("Kubelet sock has been re/created. The plugin needs a restart.")
-				nepm.setState(PluginRestarting)
-				break L
+	case nepm.socketCheck <- struct{}{}:
+	default:
+	}
+}
+
+// socketPath returns the socket of the plugin, or an empty string if the plugin does not
+// tell.
+func (nepm *NitroEnclavesPluginMonitor) socketPath() string {
+	if server, ok := nepm.devicePlugin.(ISocketServer); ok {
+		return server.SocketPath()
+	}
+	return ""
+}
+
+// checkSocket returns an error if the plugin socket is gone or no longer served, e.g.
+// because the kubelet wiped the device plugin directory.
+func (nepm *NitroEnclavesPluginMonitor) checkSocket() error {
+	socketPath := nepm.socketPath()
+	if socketPath == "" {
+		return nil
+	}
+	return CheckSocket(socketPath)
+}
+
+// CheckSocket returns an error if the unix socket at socketPath is missing or not served.
+func CheckSocket(socketPath string) error {
+	if _, err := os.Stat(socketPath); err != nil {
+		return err
+	}
+	conn, err := net.DialTimeout("unix", socketPath, socketCheckTimeout)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+// restartPlugin stops the plugin, so that the next run starts and re-registers it.
+func (nepm *NitroEnclavesPluginMonitor) restartPlugin() {
+	nepm.devicePlugin.Stop()
+	nepm.setState(PluginRestarting)
+	metrics.PluginRestarted(nepm.devicePlugin.ResourceName())
+}
+
+// run starts the plugin if it is not running and waits for the next event. It returns false
+// once ctx is cancelled and the plugin has been stopped.
+func run(ctx context.Context, nepm *NitroEnclavesPluginMonitor) bool {
+	nepm.heartbeat()
+
+	if nepm.state() != PluginRunning {
+		err := nepm.devicePlugin.Start()
+		nepm.recordStart(err)
+		if err != nil {
+			current := nepm.state()
+			if failureState := startFailureState(current, err); failureState != current {
+				nepm.setState(failureState)
+				glog.V(0).Infof("%v plugin state is: %v (Reason: %v).", nepm.devicePlugin.ResourceName(), failureState, err)
 			}
+			// Back off and try again as long as the monitor is running.
+			delay := nepm.startBackoff()
+			glog.V(1).Infof("Retrying to start %v plugin in %v.", nepm.devicePlugin.ResourceName(), delay.Round(time.Millisecond))
+			return nepm.waitForRetry(ctx, delay)
 		}
 
-	case sig := <-nepm.sigWatcher:
-		switch sig {
-		case syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT:
-			glog.V(0).Infof("Terminating plugin monitor... (Reason: \"%v\")", sig)
-			nepm.devicePlugin.Stop()
-			cont = false
-			break L
+		nepm.setState(PluginRunning)
+		glog.V(0).Infof("%v plugin state is: %v.", nepm.devicePlugin.ResourceName(), nepm.state())
+	}
+
+	select {
+	case <-nepm.restart:
+		glog.V(0).Infof("Kubelet sock has been re/created. The %v plugin needs a restart.", nepm.devicePlugin.ResourceName())
+		nepm.restartPlugin()
+
+	case <-nepm.socketCheck:
+		// The removal may also stem from a restart of the plugin itself, so only restart
+		// if the socket is actually lost.
+		if err := nepm.checkSocket(); err != nil {
+			glog.V(0).Infof("%v plugin socket has been removed (Reason: %v). The plugin needs a restart.", nepm.devicePlugin.ResourceName(), err)
+			nepm.restartPlugin()
+		}
+
+	case <-ctx.Done():
+		glog.V(0).Infof("Terminating %v plugin monitor...", nepm.devicePlugin.ResourceName())
+		nepm.devicePlugin.Stop()
+		nepm.setState(PluginIdle)
+		return false
+
+	case <-time.After(monitorHeartbeatInterval):
+		if err := nepm.checkSocket(); err != nil {
+			glog.V(0).Infof("%v plugin socket is not served (Reason: %v). The plugin needs a restart.", nepm.devicePlugin.ResourceName(), err)
+			nepm.restartPlugin()
 		}
 	}
 
-	return cont
+	return true
 }
 
-func (nepm *NitroEnclavesPluginMonitor) Run() {
-	defer nepm.fsWatcher.Close()
+// Run keeps the plugin running until ctx is cancelled, then stops it.
+func (nepm *NitroEnclavesPluginMonitor) Run(ctx context.Context) {
+	defer func() {
+		nepm.mutex.Lock()
+		nepm.stopped = true
+		nepm.mutex.Unlock()
+	}()
 
 	for ever := true; ever; {
-		ever = run(nepm)
+		ever = run(ctx, nepm)
 	}
 }
 
@@ -137,17 +328,104 @@ type IBasicDevicePlugin interface {
 	ResourceName() string
 }
 
+// ISocketServer is implemented by device plugins which tell the socket they are served on.
+// The socket is then watched and the plugin restarted if the socket is lost.
+type ISocketServer interface {
+	SocketPath() string
+}
+
+// IReadinessReporter is implemented by device plugins which can tell whether the kubelet
+// is actually using them.
+type IReadinessReporter interface {
+	// Registered reports whether the plugin is registered with the kubelet.
+	Registered() bool
+	// ListAndWatchStreams returns the number of active ListAndWatch streams.
+	ListAndWatchStreams() int
+}
+
+// IStatusReporter is implemented by device plugins which report their devices, registration
+// and allocations for introspection.
+type IStatusReporter interface {
+	Status() introspection.PluginStatus
+}
+
+// ResourceName returns the resource name of the monitored device plugin.
+func (nepm *NitroEnclavesPluginMonitor) ResourceName() string {
+	return nepm.devicePlugin.ResourceName()
+}
+
+// Ready returns an error unless the device plugin is running, registered with the kubelet
+// and serving at least one ListAndWatch stream.
+func (nepm *NitroEnclavesPluginMonitor) Ready() error {
+	if state := nepm.state(); state != PluginRunning {
+		nepm.mutex.Lock()
+		lastStartError := nepm.lastStartError
+		nepm.mutex.Unlock()
+		if lastStartError != nil {
+			return fmt.Errorf("plugin is %v: %v", state, lastStartError)
+		}
+		return fmt.Errorf("plugin is %v", state)
+	}
+	reporter, ok := nepm.devicePlugin.(IReadinessReporter)
+	if !ok {
+		return nil
+	}
+	if !reporter.Registered() {
+		return errors.New("plugin is not registered with the kubelet")
+	}
+	if reporter.ListAndWatchStreams() == 0 {
+		return errors.New("kubelet has no active ListAndWatch stream")
+	}
+	return nil
+}
+
+// Status returns the status reported by the device plugin, if any, along with the plugin
+// state and the last start error.
+func (nepm *NitroEnclavesPluginMonitor) Status() introspection.PluginStatus {
+	status := introspection.PluginStatus{ResourceName: nepm.devicePlugin.ResourceName()}
+	if reporter, ok := nepm.devicePlugin.(IStatusReporter); ok {
+		status = reporter.Status()
+	}
+
+	nepm.mutex.Lock()
+	defer nepm.mutex.Unlock()
+	status.State = nepm.pluginState.String()
+	if nepm.lastStartError != nil {
+		status.LastStartError = nepm.lastStartError.Error()
+	}
+	return status
+}
+
+// Live returns an error if the monitor loop has terminated or stalled, or if the device
+// plugin failed to start more than StartFailureThreshold times in a row.
+func (nepm *NitroEnclavesPluginMonitor) Live() error {
+	nepm.mutex.Lock()
+	defer nepm.mutex.Unlock()
+
+	if nepm.stopped {
+		return errors.New("plugin monitor has terminated")
+	}
+	if since := time.Since(nepm.lastHeartbeat); since > monitorStallTimeout {
+		return fmt.Errorf("plugin monitor has stalled for %v", since.Round(time.Second))
+	}
+	if nepm.StartFailureThreshold > 0 && nepm.startFailures >= nepm.StartFailureThreshold {
+		return fmt.Errorf("plugin failed to start %d times in a row: %v", nepm.startFailures, nepm.lastStartError)
+	}
+	return nil
+}
+
 // Create a new plugin monitor.
 func NewNitroEnclavesMonitor(nedp IBasicDevicePlugin) *NitroEnclavesPluginMonitor {
+	glog.V(0).Infof("Creating plugin monitor for %v", nedp.ResourceName())
 	nepm := &NitroEnclavesPluginMonitor{
-		devicePlugin:      nedp,
-		devicePluginPath:  pluginapi.DevicePluginPath,
-		kubeletSocketName: pluginapi.KubeletSocket,
-	}
-
-	if nepm.Init() != nil {
-		nepm = nil
+		devicePlugin:          nedp,
+		restart:               make(chan struct{}, 1),
+		socketCheck:           make(chan struct{}, 1),
+		StartFailureThreshold: DefaultStartFailureThreshold,
+		MaxStartBackoff:       DefaultMaxStartBackoff,
+		lastHeartbeat:         time.Now(),
 	}
+	nepm.setState(PluginIdle)
 
 	return nepm
 }
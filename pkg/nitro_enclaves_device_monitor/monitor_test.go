@@ -3,8 +3,10 @@
 package nitro_enclaves_device_monitor
 
 import (
+	"context"
 	"errors"
-	"os"
+	"fmt"
+	"k8s-ne-device-plugin/pkg/device_plugin_framework"
 	"testing"
 	"time"
 )
@@ -31,7 +33,7 @@ func TestNoChangeOfStateAfterPluginFailsToStart(t *testing.T) {
 	}
 
 	nepm.setState(PluginIdle)
-	run(nepm)
+	run(context.Background(), nepm)
 
 	if nepm.state() != PluginIdle {
 		t.Fatal("Expected the state = PluginIdle, but got ", nepm.state())
@@ -39,51 +41,119 @@ func TestNoChangeOfStateAfterPluginFailsToStart(t *testing.T) {
 	}
 }
 
-// Whenever the Kubelet socket is recreated, the plugin needs a restart.
-func TestIntegrationValidatePluginNeedsARestart(t *testing.T) {
-	dp := "/tmp/"
-	ksn := dp + "dummy.domain.socket"
+type DummyReadinessReporter struct {
+	DummyDevicePlugin
+	registered bool
+	streams    int
+}
 
-	nepm := &NitroEnclavesPluginMonitor{
-		devicePlugin:      &DummyDevicePlugin{},
-		devicePluginPath:  dp,
-		kubeletSocketName: ksn,
-	}
+func (d *DummyReadinessReporter) Registered() bool {
+	return d.registered
+}
+
+func (d *DummyReadinessReporter) ListAndWatchStreams() int {
+	return d.streams
+}
 
-	// Remove the dummy socket file if exists
-	os.Remove(ksn)
-	result := nepm.Init()
+func TestReadyRequiresRegistrationAndStream(t *testing.T) {
+	dp := &DummyReadinessReporter{}
+	nepm := &NitroEnclavesPluginMonitor{devicePlugin: dp}
 
-	if result != nil {
-		t.Fatal("Error while initializing plugin monitor.")
-		t.FailNow()
+	nepm.setState(PluginIdle)
+	if nepm.Ready() == nil {
+		t.Fatal("Expected an idle plugin not to be ready")
 	}
 
 	nepm.setState(PluginRunning)
-	go run(nepm)
-	// Reschedule
-	time.Sleep(100 * time.Millisecond)
+	if nepm.Ready() == nil {
+		t.Fatal("Expected an unregistered plugin not to be ready")
+	}
 
-	// Create a dummy socket file
-	fdesc, _ := os.Create(ksn)
+	dp.registered = true
+	if nepm.Ready() == nil {
+		t.Fatal("Expected a plugin without ListAndWatch stream not to be ready")
+	}
 
-	err := fdesc.Close()
-	if err != nil {
-		t.Logf("Error while creating dummy socket file.")
+	dp.streams = 1
+	if err := nepm.Ready(); err != nil {
+		t.Fatal("Expected the plugin to be ready, but got ", err)
 	}
-	defer os.Remove(ksn)
+}
 
-	// Wait for the monitor state to change.
-	for i := 0; i < 10; i++ {
-		if nepm.state() == PluginRestarting {
-			break
+func TestLiveFailsAfterRepeatedStartFailures(t *testing.T) {
+	nepm := &NitroEnclavesPluginMonitor{
+		devicePlugin:          &DummyDevicePlugin{startError: errors.New("Some failure")},
+		StartFailureThreshold: 2,
+	}
+	nepm.heartbeat()
+
+	for i := 0; i < 2; i++ {
+		if err := nepm.Live(); err != nil {
+			t.Fatal("Expected the monitor to be alive, but got ", err)
 		}
-		time.Sleep(200 * time.Millisecond)
+		nepm.recordStart(errors.New("Some failure"))
 	}
-	// Test requires direct access to /tmp folder.
-	// Might fail on macOS natively - run in container instead.
-	if nepm.state() != PluginRestarting {
-		t.Fatal("Socket file is generated, but the plugin didn't restart!")
-		t.FailNow()
+	if nepm.Live() == nil {
+		t.Fatal("Expected the monitor not to be alive after 2 start failures")
+	}
+
+	nepm.recordStart(nil)
+	if err := nepm.Live(); err != nil {
+		t.Fatal("Expected a successful start to reset the failures, but got ", err)
+	}
+
+	nepm.lastHeartbeat = time.Now().Add(-2 * monitorStallTimeout)
+	if nepm.Live() == nil {
+		t.Fatal("Expected a stalled monitor not to be alive")
+	}
+}
+
+// Registration failures are reflected in the plugin state.
+func TestStartFailureState(t *testing.T) {
+	tests := []struct {
+		err  error
+		want PluginState
+	}{
+		{err: fmt.Errorf("%w: no such file", device_plugin_framework.ErrKubeletSocketMissing), want: PluginWaitingForKubelet},
+		{err: fmt.Errorf("%w: deadline exceeded", device_plugin_framework.ErrRegistrationTimeout), want: PluginWaitingForKubelet},
+		{err: fmt.Errorf("%w: unsupported version", device_plugin_framework.ErrRegistrationRejected), want: PluginRejected},
+		{err: errors.New("Some failure"), want: PluginRestarting},
+	}
+
+	for _, tt := range tests {
+		if got := startFailureState(PluginRestarting, tt.err); got != tt.want {
+			t.Errorf("startFailureState(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
+
+// Reloaded start limits apply to a running monitor.
+func TestSetStartLimits(t *testing.T) {
+	nepm := &NitroEnclavesPluginMonitor{StartFailureThreshold: 5, MaxStartBackoff: time.Minute}
+	nepm.heartbeat()
+	for i := 0; i < 6; i++ {
+		nepm.recordStart(errors.New("Some failure"))
+	}
+	if nepm.Live() == nil {
+		t.Fatal("Expected the monitor not to be alive after 6 start failures")
+	}
+
+	nepm.SetStartLimits(0, 2*time.Second)
+	if err := nepm.Live(); err != nil {
+		t.Fatal("Expected a disabled threshold to keep the monitor alive, but got ", err)
+	}
+	if backoff := nepm.startBackoff(); backoff > 2*time.Second {
+		t.Errorf("startBackoff() = %v, want at most 2s", backoff)
+	}
+}
+
+func TestStartBackoffGrowsUpToMaximum(t *testing.T) {
+	nepm := &NitroEnclavesPluginMonitor{MaxStartBackoff: 10 * time.Second}
+
+	for failures, want := range []time.Duration{time.Second, time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 10 * time.Second, 10 * time.Second} {
+		nepm.startFailures = failures
+		if backoff := nepm.startBackoff(); backoff < want/2 || backoff > want {
+			t.Errorf("startBackoff() after %d failures = %v, want between %v and %v", failures, backoff, want/2, want)
+		}
 	}
 }
@@ -0,0 +1,139 @@
+// Copyright 2025 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package nitro_enclaves_device_monitor
+
+import (
+	"context"
+	"k8s-ne-device-plugin/pkg/config"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/golang/glog"
+	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+)
+
+// NitroEnclavesSupervisor runs the monitors of all device plugins of the process. It owns the
+// only termination signal handler and the only device plugin directory watcher, restarts the
+// plugins when the kubelet comes back or wipes their sockets and stops them in reverse order
+// of their start on shutdown.
+type NitroEnclavesSupervisor struct {
+	// Mode selects how changes of the kubelet and plugin sockets are detected.
+	Mode config.WatchMode
+	// PollInterval is the interval of checking the sockets in config.WatchModePoll.
+	PollInterval time.Duration
+
+	monitors          []*NitroEnclavesPluginMonitor
+	devicePluginPath  string
+	kubeletSocketName string
+}
+
+// NewNitroEnclavesSupervisor creates a supervisor without any plugin, see Add, watching the
+// kubelet socket in devicePluginDir, usually pluginapi.DevicePluginPath.
+func NewNitroEnclavesSupervisor(devicePluginDir string) *NitroEnclavesSupervisor {
+	return &NitroEnclavesSupervisor{
+		Mode:              config.WatchModeAuto,
+		PollInterval:      DefaultPollInterval,
+		devicePluginPath:  filepath.Clean(devicePluginDir),
+		kubeletSocketName: filepath.Join(devicePluginDir, filepath.Base(pluginapi.KubeletSocket)),
+	}
+}
+
+// Add creates the monitor of plugin. Plugins are started in the order they are added.
+func (nes *NitroEnclavesSupervisor) Add(plugin IBasicDevicePlugin) *NitroEnclavesPluginMonitor {
+	nepm := NewNitroEnclavesMonitor(plugin)
+	nes.monitors = append(nes.monitors, nepm)
+	return nepm
+}
+
+// Monitors returns the monitors of all added plugins.
+func (nes *NitroEnclavesSupervisor) Monitors() []*NitroEnclavesPluginMonitor {
+	return nes.monitors
+}
+
+// supervisedMonitor is a monitor running under the supervisor.
+type supervisedMonitor struct {
+	monitor *NitroEnclavesPluginMonitor
+	cancel  context.CancelFunc
+	done    chan struct{}
+}
+
+// Run runs all plugins until ctx is cancelled or a termination signal is received. All plugins
+// are stopped when Run returns.
+func (nes *NitroEnclavesSupervisor) Run(ctx context.Context) error {
+	sockets := []string{nes.kubeletSocketName}
+	for _, nepm := range nes.monitors {
+		if socketPath := nepm.socketPath(); socketPath != "" {
+			sockets = append(sockets, socketPath)
+		}
+	}
+	watcher, err := newSocketWatcher(nes.Mode, nes.devicePluginPath, sockets, nes.PollInterval)
+	if err != nil {
+		glog.Errorf("Error while watching %s: %v", nes.devicePluginPath, err)
+		return err
+	}
+	defer watcher.close()
+
+	sigWatcher := make(chan os.Signal, 1)
+	signal.Notify(sigWatcher, syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT)
+	defer signal.Stop(sigWatcher)
+
+	// Each monitor gets its own context, so that the shutdown order is not left to the
+	// scheduler.
+	running := make([]supervisedMonitor, 0, len(nes.monitors))
+	for _, nepm := range nes.monitors {
+		monitorCtx, cancel := context.WithCancel(context.Background())
+		sm := supervisedMonitor{monitor: nepm, cancel: cancel, done: make(chan struct{})}
+		go func() {
+			defer close(sm.done)
+			sm.monitor.Run(monitorCtx)
+		}()
+		running = append(running, sm)
+	}
+	defer nes.shutdown(running)
+	glog.V(0).Infof("Plugin supervisor is running %d plugin(s).", len(running))
+
+	for {
+		select {
+		case fsEvent := <-watcher.events:
+			if fsEvent.Name == nes.kubeletSocketName && fsEvent.Op&fsnotify.Create == fsnotify.Create {
+				glog.V(0).Infof("Kubelet sock has been re/created. All plugins need a restart.")
+				for _, sm := range running {
+					sm.monitor.requestRestart()
+				}
+			}
+			if fsEvent.Op&(fsnotify.Remove|fsnotify.Rename) != 0 {
+				for _, sm := range running {
+					if sm.monitor.socketPath() == fsEvent.Name {
+						sm.monitor.requestSocketCheck()
+					}
+				}
+			}
+
+		case err := <-watcher.errors:
+			glog.Errorf("Error while watching %s: %v", nes.devicePluginPath, err)
+
+		case sig := <-sigWatcher:
+			glog.V(0).Infof("Terminating plugin supervisor... (Reason: \"%v\")", sig)
+			return nil
+
+		case <-ctx.Done():
+			glog.V(0).Infof("Terminating plugin supervisor... (Reason: \"%v\")", ctx.Err())
+			return nil
+		}
+	}
+}
+
+// shutdown stops the monitors in reverse order of their start, waiting for each plugin to be
+// stopped before the next one.
+func (nes *NitroEnclavesSupervisor) shutdown(running []supervisedMonitor) {
+	for i := len(running) - 1; i >= 0; i-- {
+		running[i].cancel()
+		<-running[i].done
+	}
+	glog.V(0).Info("All plugins have been stopped.")
+}
@@ -0,0 +1,215 @@
+// Copyright 2025 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+package nitro_enclaves_device_monitor
+
+import (
+	"context"
+	"k8s-ne-device-plugin/pkg/config"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// eventLog records the start and stop calls of several plugins in order.
+type eventLog struct {
+	mutex  sync.Mutex
+	events []string
+}
+
+func (l *eventLog) add(event string) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.events = append(l.events, event)
+}
+
+func (l *eventLog) get() []string {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return append([]string(nil), l.events...)
+}
+
+type RecordingDevicePlugin struct {
+	name string
+	log  *eventLog
+}
+
+func (r *RecordingDevicePlugin) Start() error {
+	r.log.add("start " + r.name)
+	return nil
+}
+
+func (r *RecordingDevicePlugin) Stop() {
+	r.log.add("stop " + r.name)
+}
+
+func (r *RecordingDevicePlugin) ResourceName() string {
+	return "aws.ec2.nitro/" + r.name
+}
+
+// waitForState waits for all monitors to reach state.
+func waitForState(t *testing.T, monitors []*NitroEnclavesPluginMonitor, state PluginState) {
+	t.Helper()
+	for i := 0; i < 50; i++ {
+		reached := true
+		for _, nepm := range monitors {
+			reached = reached && nepm.state() == state
+		}
+		if reached {
+			return
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	t.Fatalf("Plugins did not reach the %v state", state)
+}
+
+// Whenever the Kubelet socket is recreated, all plugins need a restart. On shutdown, the
+// plugins are stopped in reverse order.
+func TestSupervisorRestartsAndStopsPlugins(t *testing.T) {
+	for _, mode := range []config.WatchMode{config.WatchModeFsnotify, config.WatchModePoll} {
+		t.Run(string(mode), func(t *testing.T) {
+			testSupervisorRestartsAndStopsPlugins(t, mode)
+		})
+	}
+}
+
+func testSupervisorRestartsAndStopsPlugins(t *testing.T, mode config.WatchMode) {
+	dp := t.TempDir()
+	log := &eventLog{}
+
+	nes := NewNitroEnclavesSupervisor(dp)
+	nes.Mode = mode
+	nes.PollInterval = 50 * time.Millisecond
+	nes.Add(&RecordingDevicePlugin{name: "first", log: log})
+	nes.Add(&RecordingDevicePlugin{name: "second", log: log})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- nes.Run(ctx)
+	}()
+	waitForState(t, nes.Monitors(), PluginRunning)
+
+	// Create a dummy socket file
+	if err := os.WriteFile(filepath.Join(dp, "kubelet.sock"), nil, 0600); err != nil {
+		t.Fatalf("Error while creating dummy socket file: %v", err)
+	}
+
+	// The monitors stop and start their plugins right after the restart request.
+	for i := 0; i < 50 && len(log.get()) < 6; i++ {
+		time.Sleep(100 * time.Millisecond)
+	}
+	if events := log.get(); len(events) < 6 {
+		t.Fatalf("Socket file is generated, but the plugins didn't restart: %v", events)
+	}
+	waitForState(t, nes.Monitors(), PluginRunning)
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal("Run() error = ", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Supervisor did not terminate")
+	}
+
+	events := log.get()
+	if stops := events[len(events)-2:]; !reflect.DeepEqual(stops, []string{"stop second", "stop first"}) {
+		t.Errorf("Plugins were stopped as %v, want the reverse order of their start", stops)
+	}
+	for _, nepm := range nes.Monitors() {
+		if nepm.Live() == nil {
+			t.Errorf("Expected the %v monitor not to be alive after shutdown", nepm.ResourceName())
+		}
+	}
+}
+
+// ServingDevicePlugin serves its socket while it is started.
+type ServingDevicePlugin struct {
+	RecordingDevicePlugin
+	socket   string
+	listener net.Listener
+}
+
+func (s *ServingDevicePlugin) Start() error {
+	s.RecordingDevicePlugin.Start()
+	os.Remove(s.socket)
+	listener, err := net.Listen("unix", s.socket)
+	s.listener = listener
+	return err
+}
+
+func (s *ServingDevicePlugin) Stop() {
+	s.RecordingDevicePlugin.Stop()
+	if s.listener != nil {
+		s.listener.Close()
+		s.listener = nil
+	}
+	os.Remove(s.socket)
+}
+
+func (s *ServingDevicePlugin) SocketPath() string {
+	return s.socket
+}
+
+// When the kubelet wipes the socket of a plugin, only this plugin is restarted.
+func TestSupervisorRestartsPluginWithLostSocket(t *testing.T) {
+	dp := t.TempDir()
+	log := &eventLog{}
+
+	nes := NewNitroEnclavesSupervisor(dp)
+	serving := &ServingDevicePlugin{
+		RecordingDevicePlugin: RecordingDevicePlugin{name: "serving", log: log},
+		socket:                filepath.Join(dp, "serving.sock"),
+	}
+	nes.Add(serving)
+	nes.Add(&RecordingDevicePlugin{name: "other", log: log})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go nes.Run(ctx)
+	waitForState(t, nes.Monitors(), PluginRunning)
+
+	if err := nes.Monitors()[0].checkSocket(); err != nil {
+		t.Fatal("Expected the plugin socket to be served, but got ", err)
+	}
+	if err := os.Remove(serving.socket); err != nil {
+		t.Fatalf("Error while removing the plugin socket: %v", err)
+	}
+
+	want := []string{"start serving", "start other", "stop serving", "start serving"}
+	for i := 0; i < 50 && len(log.get()) < len(want); i++ {
+		time.Sleep(100 * time.Millisecond)
+	}
+	// the plugins start concurrently
+	events := log.get()
+	if len(events) != len(want) || !reflect.DeepEqual(events[2:], want[2:]) {
+		t.Fatalf("Plugin socket is removed, but got events %v, want %v", events, want)
+	}
+	waitForState(t, nes.Monitors(), PluginRunning)
+	if err := nes.Monitors()[0].checkSocket(); err != nil {
+		t.Fatal("Expected the plugin socket to be served again, but got ", err)
+	}
+}
+
+func TestCheckSocketDetectsStaleSocket(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "stale.sock")
+	nepm := NewNitroEnclavesMonitor(&ServingDevicePlugin{
+		RecordingDevicePlugin: RecordingDevicePlugin{name: "stale", log: &eventLog{}},
+		socket:                socket,
+	})
+
+	if nepm.checkSocket() == nil {
+		t.Fatal("Expected a missing socket to fail the check")
+	}
+	if err := os.WriteFile(socket, nil, 0600); err != nil {
+		t.Fatalf("Error while creating stale socket file: %v", err)
+	}
+	if nepm.checkSocket() == nil {
+		t.Fatal("Expected a socket which is not served to fail the check")
+	}
+}
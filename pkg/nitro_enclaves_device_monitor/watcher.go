@@ -0,0 +1,131 @@
+// Copyright 2025 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package nitro_enclaves_device_monitor
+
+import (
+	"fmt"
+	"k8s-ne-device-plugin/pkg/config"
+	"os"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/golang/glog"
+)
+
+// DefaultPollInterval is the default interval of config.WatchModePoll.
+const DefaultPollInterval = 5 * time.Second
+
+// socketWatcher delivers changes of the kubelet and plugin sockets as fsnotify events.
+type socketWatcher struct {
+	events <-chan fsnotify.Event
+	errors <-chan error
+	close  func()
+}
+
+// newFsnotifyWatcher watches the device plugin directory via inotify.
+func newFsnotifyWatcher(dir string) (*socketWatcher, error) {
+	fsWatcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, fmt.Errorf("error while creating file system watcher: %w", err)
+	}
+	if err = fsWatcher.Add(dir); err != nil {
+		fsWatcher.Close()
+		return nil, fmt.Errorf("error while accessing %s: %w", dir, err)
+	}
+
+	return &socketWatcher{
+		events: fsWatcher.Events,
+		errors: fsWatcher.Errors,
+		close:  func() { fsWatcher.Close() },
+	}, nil
+}
+
+// socketChanged reports whether a socket has been replaced or modified between two checks.
+func socketChanged(previous, current os.FileInfo) bool {
+	return !os.SameFile(previous, current) || !previous.ModTime().Equal(current.ModTime())
+}
+
+// newPollWatcher checks the given sockets every interval. A socket which disappears or is
+// replaced yields a Remove event, a socket which appears or is replaced yields a Create event.
+func newPollWatcher(paths []string, interval time.Duration) *socketWatcher {
+	events := make(chan fsnotify.Event)
+	stop := make(chan struct{})
+
+	stat := func(path string) os.FileInfo {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil
+		}
+		return info
+	}
+
+	previous := make(map[string]os.FileInfo, len(paths))
+	for _, path := range paths {
+		previous[path] = stat(path)
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+			case <-stop:
+				return
+			}
+
+			for _, path := range paths {
+				current := stat(path)
+				changed := previous[path] != nil && current != nil && socketChanged(previous[path], current)
+				var ops []fsnotify.Op
+				if previous[path] != nil && (current == nil || changed) {
+					ops = append(ops, fsnotify.Remove)
+				}
+				if current != nil && (previous[path] == nil || changed) {
+					ops = append(ops, fsnotify.Create)
+				}
+				previous[path] = current
+
+				for _, op := range ops {
+					select {
+					case events <- fsnotify.Event{Name: path, Op: op}:
+					case <-stop:
+						return
+					}
+				}
+			}
+		}
+	}()
+
+	return &socketWatcher{
+		events: events,
+		close:  func() { close(stop) },
+	}
+}
+
+// newSocketWatcher creates the watcher of the given mode for the kubelet socket and the plugin
+// sockets in dir.
+func newSocketWatcher(mode config.WatchMode, dir string, sockets []string, interval time.Duration) (*socketWatcher, error) {
+	if interval <= 0 {
+		interval = DefaultPollInterval
+	}
+
+	switch mode {
+	case config.WatchModePoll:
+		glog.V(0).Infof("Polling %v every %v.", sockets, interval)
+		return newPollWatcher(sockets, interval), nil
+	case config.WatchModeFsnotify:
+		return newFsnotifyWatcher(dir)
+	case config.WatchModeAuto, "":
+		watcher, err := newFsnotifyWatcher(dir)
+		if err != nil {
+			glog.Warningf("Falling back to polling %v every %v: %v", sockets, interval, err)
+			return newPollWatcher(sockets, interval), nil
+		}
+		return watcher, nil
+	default:
+		return nil, fmt.Errorf("unknown watch mode %q", mode)
+	}
+}
@@ -0,0 +1,69 @@
+// Copyright 2025 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+package nitro_enclaves_device_monitor
+
+import (
+	"k8s-ne-device-plugin/pkg/config"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+func TestPollWatcherReportsSocketChanges(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "kubelet.sock")
+	watcher := newPollWatcher([]string{socket}, 10*time.Millisecond)
+	defer watcher.close()
+
+	expect := func(op fsnotify.Op) {
+		t.Helper()
+		select {
+		case event := <-watcher.events:
+			if event.Name != socket || event.Op != op {
+				t.Fatalf("Got event %v, want %v of %s", event, op, socket)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Timed out waiting for %v of %s", op, socket)
+		}
+	}
+
+	if err := os.WriteFile(socket, nil, 0600); err != nil {
+		t.Fatalf("Error while creating dummy socket file: %v", err)
+	}
+	expect(fsnotify.Create)
+
+	// a replaced socket is reported as removed and created again
+	if err := os.WriteFile(socket+".new", nil, 0600); err != nil {
+		t.Fatalf("Error while creating dummy socket file: %v", err)
+	}
+	if err := os.Rename(socket+".new", socket); err != nil {
+		t.Fatalf("Error while replacing dummy socket file: %v", err)
+	}
+	expect(fsnotify.Remove)
+	expect(fsnotify.Create)
+
+	if err := os.Remove(socket); err != nil {
+		t.Fatalf("Error while removing dummy socket file: %v", err)
+	}
+	expect(fsnotify.Remove)
+}
+
+func TestSocketWatcherModes(t *testing.T) {
+	missing := filepath.Join(t.TempDir(), "missing")
+
+	if _, err := newSocketWatcher(config.WatchModeFsnotify, missing, nil, 0); err == nil {
+		t.Error("Expected the fsnotify mode to fail for a missing directory")
+	}
+	if _, err := newSocketWatcher("inotify", missing, nil, 0); err == nil {
+		t.Error("Expected an unknown mode to fail")
+	}
+
+	// the auto mode falls back to polling
+	watcher, err := newSocketWatcher(config.WatchModeAuto, missing, nil, 0)
+	if err != nil {
+		t.Fatal("Expected the auto mode to fall back to polling, but got ", err)
+	}
+	watcher.close()
+}
@@ -0,0 +1,75 @@
+// Copyright 2026 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package nitro_enclaves_device_plugin
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	// cidEnv holds the first vsock CID reserved for the enclave of the container.
+	cidEnv = "NITRO_ENCLAVE_CID"
+	// cidsEnv holds all vsock CIDs reserved for the container, as comma separated CIDs or
+	// ranges, one per allocated device.
+	cidsEnv = "NITRO_ENCLAVE_CIDS"
+)
+
+// parseDeviceID returns the slot of the device with the given ID, see generateDeviceID.
+func parseDeviceID(id string) (int, error) {
+	slot, err := strconv.Atoi(strings.TrimPrefix(id, deviceName+"_"))
+	if err != nil || slot < 0 || !strings.HasPrefix(id, deviceName+"_") {
+		return 0, fmt.Errorf("invalid enclave device ID: %s", id)
+	}
+	return slot, nil
+}
+
+// cidRange is the range of vsock CIDs reserved for every device slot. CIDs only depend on
+// the slot, so running enclaves keep their CIDs across plugin restarts.
+type cidRange struct {
+	// base is the first CID of slot 0, zero disables CIDs.
+	base    int
+	perSlot int
+}
+
+// slot returns the first and last CID of slot.
+func (r cidRange) slot(slot int) (int, int) {
+	first := r.base + slot*r.perSlot
+	return first, first + r.perSlot - 1
+}
+
+// envs returns the environment variables telling a container the CIDs of the devices with
+// the given IDs.
+func (r cidRange) envs(ids []string) (map[string]string, error) {
+	if r.base == 0 || len(ids) == 0 {
+		return nil, nil
+	}
+
+	slots := make([]int, 0, len(ids))
+	for _, id := range ids {
+		slot, err := parseDeviceID(id)
+		if err != nil {
+			return nil, err
+		}
+		slots = append(slots, slot)
+	}
+	sort.Ints(slots)
+
+	cids := make([]string, 0, len(slots))
+	for _, slot := range slots {
+		if first, last := r.slot(slot); first == last {
+			cids = append(cids, strconv.Itoa(first))
+		} else {
+			cids = append(cids, strconv.Itoa(first)+"-"+strconv.Itoa(last))
+		}
+	}
+	first, _ := r.slot(slots[0])
+
+	return map[string]string{
+		cidEnv:  strconv.Itoa(first),
+		cidsEnv: strings.Join(cids, ","),
+	}, nil
+}
@@ -0,0 +1,63 @@
+// Copyright 2026 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package nitro_enclaves_device_plugin
+
+import (
+	"k8s-ne-device-plugin/pkg/config"
+	"reflect"
+	"testing"
+
+	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+)
+
+func TestParseDeviceID(t *testing.T) {
+	if slot, err := parseDeviceID("nitro_enclaves_3"); err != nil || slot != 3 {
+		t.Errorf("parseDeviceID(nitro_enclaves_3) = %v, %v, want 3", slot, err)
+	}
+	for _, id := range []string{"nitro_enclaves", "nitro_enclaves_-1", "cpu_3", "3", "nitro_enclaves_cpus_3"} {
+		if _, err := parseDeviceID(id); err == nil {
+			t.Errorf("Expected parseDeviceID(%s) to fail", id)
+		}
+	}
+}
+
+// Every slot gets its own CIDs, which are the same for every plugin instance.
+func TestAllocateCIDs(t *testing.T) {
+	allocate := func(c *config.PluginConfig, ids ...string) (map[string]string, error) {
+		resp, err := NewNitroEnclavesDevicePlugin(c).ContainerAllocate(&pluginapi.ContainerAllocateRequest{DevicesIDs: ids})
+		if err != nil {
+			return nil, err
+		}
+		return resp.Envs, nil
+	}
+
+	single := &config.PluginConfig{MaxEnclavesPerNode: 4, EnclaveCIDBase: 16, EnclaveCIDsPerSlot: 1}
+	for i := 0; i < 2; i++ {
+		envs, err := allocate(single, "nitro_enclaves_2", "nitro_enclaves_0")
+		if err != nil {
+			t.Fatalf("ContainerAllocate() error = %v", err)
+		}
+		if want := map[string]string{cidEnv: "16", cidsEnv: "16,18"}; !reflect.DeepEqual(envs, want) {
+			t.Errorf("ContainerAllocate() envs = %v, want %v", envs, want)
+		}
+	}
+
+	ranges := &config.PluginConfig{MaxEnclavesPerNode: 4, EnclaveCIDBase: 100, EnclaveCIDsPerSlot: 4}
+	envs, err := allocate(ranges, "nitro_enclaves_3")
+	if err != nil {
+		t.Fatalf("ContainerAllocate() error = %v", err)
+	}
+	if want := map[string]string{cidEnv: "112", cidsEnv: "112-115"}; !reflect.DeepEqual(envs, want) {
+		t.Errorf("ContainerAllocate() envs = %v, want %v", envs, want)
+	}
+
+	if _, err = allocate(single, "nitro_enclaves_x"); err == nil {
+		t.Error("Expected ContainerAllocate() to fail for an unknown device ID")
+	}
+
+	disabled := &config.PluginConfig{MaxEnclavesPerNode: 4}
+	if envs, err = allocate(disabled, "nitro_enclaves_0"); err != nil || len(envs) != 0 {
+		t.Errorf("ContainerAllocate() = %v, %v, want no envs with CIDs disabled", envs, err)
+	}
+}
@@ -5,246 +5,320 @@ package nitro_enclaves_device_plugin
 
 import (
 	"errors"
-	"google.golang.org/grpc/credentials/insecure"
+	"fmt"
+	"io/fs"
 	"k8s-ne-device-plugin/pkg/config"
-	"k8s-ne-device-plugin/pkg/nitro_enclaves_device_monitor"
-	"net"
+	"k8s-ne-device-plugin/pkg/device_plugin_framework"
 	"os"
-	"path"
+	"path/filepath"
 	"strconv"
+	"sync"
 	"time"
 
+	"github.com/fsnotify/fsnotify"
 	"github.com/golang/glog"
-	"golang.org/x/net/context"
-	"google.golang.org/grpc"
 	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
 )
 
 const (
-	deviceName                     = "nitro_enclaves"
-	devicePluginServerReadyTimeout = 10
+	deviceName                = "nitro_enclaves"
+	containerDevicePath       = "/dev/" + deviceName
+	deviceHealthCheckInterval = 5 * time.Second
 )
 
-var deviceIdCounter = 0
-
 type IPluginDefinitions interface {
-	socketPath() string
+	// devicePath returns the path the plugin checks the device file at.
 	devicePath() string
+	// hostDevicePath returns the path of the device file on the host.
+	hostDevicePath() string
 }
 
 type NEPluginDefinitions struct {
 	IPluginDefinitions
-}
-
-func (n *NEPluginDefinitions) socketPath() string {
-	return pluginapi.DevicePluginPath + deviceName + ".sock"
+	config *config.PluginConfig
 }
 
 func (n *NEPluginDefinitions) devicePath() string {
-	return "/dev/" + deviceName
+	return n.config.DevPath(deviceName)
 }
 
-func (nedp *NitroEnclavesDevicePlugin) ResourceName() string {
-	return "aws.ec2.nitro/" + deviceName
+func (n *NEPluginDefinitions) hostDevicePath() string {
+	return n.config.HostDevPath(deviceName)
 }
 
-// NitroEnclavesDevicePlugin implements the Kubernetes device plugin API
+// NitroEnclavesDevicePlugin advertises the Nitro Enclaves device as "aws.ec2.nitro/nitro_enclaves".
 type NitroEnclavesDevicePlugin struct {
-	dev  []*pluginapi.Device
-	pdef IPluginDefinitions
-
-	stop   chan interface{}
-	health chan *pluginapi.Device
-
-	server *grpc.Server
-
-	pluginapi.DevicePluginServer
-	nitro_enclaves_device_monitor.IBasicDevicePlugin
+	*device_plugin_framework.Plugin
+
+	// dev holds every slot ever advertised. Slots beyond slots were removed by a reload and are
+	// kept as unhealthy, so that the kubelet can still account for pods holding them.
+	dev []*pluginapi.Device
+	// slots is the number of slots in use, i.e. MaxEnclavesPerNode.
+	slots int
+	// deviceHealth is the health of the host device file, shared by all slots in use.
+	deviceHealth string
+	pdef         IPluginDefinitions
+	// cids is taken from the initial config only, as running enclaves keep their CIDs.
+	cids cidRange
+	// extraDevices and extraMounts are added to every allocation.
+	extraDevices []config.DeviceSpec
+	extraMounts  []config.Mount
+
+	health chan string
+
+	// mutex guards dev, slots, deviceHealth, extraDevices and extraMounts.
+	mutex sync.Mutex
 }
 
-func generateDeviceID(deviceName string) string {
-	ctr := deviceIdCounter
-	deviceIdCounter++
-	return deviceName + "_" + strconv.Itoa(ctr)
+// generateDeviceID returns the ID of the device in the given slot, e.g. "nitro_enclaves_0". IDs
+// only depend on the slot, so that the devices in the checkpoint of the kubelet are advertised
+// again after a plugin restart.
+func generateDeviceID(slot int) string {
+	return deviceName + "_" + strconv.Itoa(slot)
 }
 
-func (nedp *NitroEnclavesDevicePlugin) releaseResources() {
-	nedp.server = nil
-	// check if socketPath does exist and delete otherwise do nothing
-	_, err := os.Stat(nedp.pdef.socketPath())
-	if err == nil {
-		err = os.Remove(nedp.pdef.socketPath())
-		if err != nil {
-			glog.Errorf("Error removing socket file: %s", err)
-		}
-	}
-}
+// openFile opens the device file, replaced in tests.
+var openFile = os.OpenFile
 
-// Register the device plugin with Kubelet.
-func (nedp *NitroEnclavesDevicePlugin) register(kubeletEndpoint, resourceName string) error {
-	glog.V(0).Infof("Attempting %v device plugin to connect to kubelet...", nedp.ResourceName())
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
-
-	opts := []grpc.DialOption{
-		grpc.WithTransportCredentials(insecure.NewCredentials()),
-		//lint:ignore SA1019 grpc.WithBlock is deprecated, not supported by grpc.NewClient
-		grpc.WithBlock(),
-		grpc.WithContextDialer(func(ctx context.Context, addr string) (net.Conn, error) {
-			return net.DialUnix("unix", nil, &net.UnixAddr{Name: addr, Net: "unix"})
-		}),
+// openDevice verifies that the Nitro Enclaves device file is present and can be opened.
+func openDevice(devicePath string) error {
+	f, err := openFile(devicePath, os.O_RDWR, 0)
+	if err != nil {
+		return err
 	}
+	if err = f.Close(); err != nil {
+		glog.Errorf("Error closing device file %s: %v", devicePath, err)
+	}
+	return nil
+}
 
-	//lint:ignore SA1019 grpc.DialContext is deprecated // todo replace by grpc.NewClient
-	conn, err := grpc.DialContext(
-		ctx,
-		kubeletEndpoint,
-		opts...,
-	)
-	defer func(conn *grpc.ClientConn) {
-		err := conn.Close()
-		if err != nil {
-			glog.Errorf("Error closing connection to kubelet: %s", err)
-		}
-	}(conn)
-
+// statDevice verifies that the Nitro Enclaves device file is present and a character device.
+func statDevice(devicePath string) error {
+	info, err := os.Stat(devicePath)
 	if err != nil {
-		glog.Errorf("Couldn't connect to kubelet! (Reason: %s)", err)
 		return err
 	}
-	glog.V(0).Infof("Connected %v device plugin to kubelet", nedp.ResourceName())
-
-	client := pluginapi.NewRegistrationClient(conn)
-	_, err = client.Register(ctx, &pluginapi.RegisterRequest{
-		Version:      pluginapi.Version,
-		Endpoint:     path.Base(nedp.pdef.socketPath()),
-		ResourceName: resourceName,
-	})
-
-	return err
+	if info.Mode()&os.ModeCharDevice == 0 {
+		return fmt.Errorf("%s (%v) is not a character device", devicePath, info.Mode())
+	}
+	return nil
 }
 
-// Ensure that the gRPC server of the device plugin is ready to serve.
-func (nedp *NitroEnclavesDevicePlugin) waitForServerReady(timeout int) error {
-	for i := 0; i < timeout; i++ {
-		info := nedp.server.GetServiceInfo()
-		if len(info) >= 1 {
-			return nil
-		}
-		time.Sleep(time.Second)
+// checkDeviceHealth reports the health of the Nitro Enclaves device file, see openDevice. The
+// unprivileged plugin container has no access to the device in its device cgroup, so opening it
+// is denied even though the device works for enclave containers, which get access through the
+// allocation. In that case the device is healthy as long as it is present, see statDevice.
+func checkDeviceHealth(devicePath string) string {
+	err := openDevice(devicePath)
+	if errors.Is(err, fs.ErrPermission) {
+		glog.V(2).Infof("Nitro Enclaves device %s can't be opened, checking its presence: %v", devicePath, err)
+		err = statDevice(devicePath)
+	}
+	if err != nil {
+		glog.V(1).Infof("Nitro Enclaves device %s is not usable: %v", devicePath, err)
+		return pluginapi.Unhealthy
 	}
+	return pluginapi.Healthy
+}
 
-	return errors.New("gRPC server initialization timed out")
+// DeviceName returns the name of the resource within the aws.ec2.nitro namespace.
+func (nedp *NitroEnclavesDevicePlugin) DeviceName() string {
+	return deviceName
 }
 
-// Allocate is called during container creation so that the Device
-// Plugin can run device specific operations and instruct Kubelet
-// of the steps to make the Device available in the container
-func (nedp *NitroEnclavesDevicePlugin) Allocate(ctx context.Context, reqs *pluginapi.AllocateRequest) (*pluginapi.AllocateResponse, error) {
-	responses := pluginapi.AllocateResponse{}
-	for _, req := range reqs.ContainerRequests {
-		response := pluginapi.ContainerAllocateResponse{
-			Devices: []*pluginapi.DeviceSpec{
-				{
-					ContainerPath: nedp.pdef.devicePath(),
-					HostPath:      nedp.pdef.devicePath(),
-					Permissions:   "rw",
-				},
-			},
-		}
+// Devices returns a snapshot of the advertised devices, safe to hand out to ListAndWatch streams.
+func (nedp *NitroEnclavesDevicePlugin) Devices() []*pluginapi.Device {
+	nedp.mutex.Lock()
+	defer nedp.mutex.Unlock()
 
-		for _, id := range req.DevicesIDs {
-			glog.V(1).Info("Allocation request for device ID: ", id)
-		}
+	return device_plugin_framework.CopyDevices(nedp.dev)
+}
 
-		responses.ContainerResponses = append(responses.ContainerResponses, &response)
+// slotHealth returns the health of the given slot. Must be called with the mutex held.
+func (nedp *NitroEnclavesDevicePlugin) slotHealth(slot int) string {
+	if slot >= nedp.slots {
+		return pluginapi.Unhealthy
 	}
+	return nedp.deviceHealth
+}
 
-	return &responses, nil
+// applyHealth applies the health of every slot to the advertised devices and returns whether
+// anything changed. Must be called with the mutex held.
+func (nedp *NitroEnclavesDevicePlugin) applyHealth() bool {
+	changed := false
+	for i, d := range nedp.dev {
+		if health := nedp.slotHealth(i); d.Health != health {
+			d.Health = health
+			changed = true
+		}
+	}
+	return changed
 }
 
-// GetDevicePluginOptions returns options to be communicated with Device Manager.
-func (nedp *NitroEnclavesDevicePlugin) GetDevicePluginOptions(context.Context, *pluginapi.Empty) (*pluginapi.DevicePluginOptions, error) {
-	return &pluginapi.DevicePluginOptions{}, nil
+// setHealth applies the given health to all slots in use and notifies every active ListAndWatch
+// stream if anything changed. All slots share the same host device file, thus they become
+// healthy or unhealthy together.
+func (nedp *NitroEnclavesDevicePlugin) setHealth(health string) {
+	nedp.mutex.Lock()
+	nedp.deviceHealth = health
+	changed := nedp.applyHealth()
+	nedp.mutex.Unlock()
+
+	if !changed {
+		return
+	}
+
+	glog.V(0).Infof("%v devices are now %v", nedp.ResourceName(), health)
+	nedp.Update()
 }
 
-// GetPreferredAllocation returns a preferred set of devices to allocate
-// from a list of available ones. The resulting preferred allocation is not
-// guaranteed to be the allocation ultimately performed by the
-// devicemanager. It is only designed to help the devicemanager make a more
-// informed allocation decision when possible.
-func (*NitroEnclavesDevicePlugin) GetPreferredAllocation(context.Context, *pluginapi.PreferredAllocationRequest) (*pluginapi.PreferredAllocationResponse, error) {
-	return &pluginapi.PreferredAllocationResponse{}, nil
+// CheckHealth checks the device file once and applies its health to all advertised devices,
+// e.g. to report the health without running the health checker, see Watch.
+func (nedp *NitroEnclavesDevicePlugin) CheckHealth() {
+	nedp.setHealth(checkDeviceHealth(nedp.pdef.devicePath()))
 }
 
-// ListAndWatch returns a stream of List of Devices
-// Whenever a Device state change or a Device disappears, ListAndWatch
-// returns the new list
-func (nedp *NitroEnclavesDevicePlugin) ListAndWatch(e *pluginapi.Empty, s pluginapi.DevicePlugin_ListAndWatchServer) error {
-	err := s.Send(&pluginapi.ListAndWatchResponse{Devices: nedp.dev})
-	if err != nil {
-		return err
+// Reconfigure resizes the slots in use to the MaxEnclavesPerNode of the given config and
+// notifies every active ListAndWatch stream. Slots added at runtime share the health of the
+// existing ones, as all of them are backed by the same host device file. Removed slots are kept
+// as unhealthy, like devices leaving a DevicePool. The extra devices and mounts apply to
+// subsequent allocations.
+func (nedp *NitroEnclavesDevicePlugin) Reconfigure(config *config.PluginConfig) {
+	nedp.mutex.Lock()
+	nedp.extraDevices, nedp.extraMounts = config.EnclaveExtraDevices, config.EnclaveExtraMounts
+	current := nedp.slots
+	if config.MaxEnclavesPerNode == current {
+		nedp.mutex.Unlock()
+		return
 	}
 
-	//TODO: Device health check goes here
-	<-nedp.stop
-	return nil
+	nedp.slots = config.MaxEnclavesPerNode
+	for i := len(nedp.dev); i < nedp.slots; i++ {
+		nedp.dev = append(nedp.dev, &pluginapi.Device{ID: generateDeviceID(i)})
+	}
+	nedp.applyHealth()
+	nedp.mutex.Unlock()
 
+	glog.V(0).Infof("Enclave devices changed from %v to %v", current, config.MaxEnclavesPerNode)
+	nedp.Update()
 }
 
-// PreStartContainer is called, if indicated by Device Plugin during registration phase,
-// before each container start. Device plugin can run device specific operations
-// such as resetting the device before making devices available to the container.
-func (nedp *NitroEnclavesDevicePlugin) PreStartContainer(context.Context, *pluginapi.PreStartContainerRequest) (*pluginapi.PreStartContainerResponse, error) {
-	return &pluginapi.PreStartContainerResponse{}, nil
+// Watch runs the device health checker until stop is closed.
+func (nedp *NitroEnclavesDevicePlugin) Watch(stop <-chan interface{}) {
+	go nedp.handleHealthUpdates(stop)
+	nedp.watchDeviceHealth(stop)
 }
 
-// Start device plugin server
-func (nedp *NitroEnclavesDevicePlugin) Start() error {
-	nedp.releaseResources()
-	glog.V(0).Info("Starting Nitro Enclaves device plugin server...")
-
-	sock, err := net.Listen("unix", nedp.pdef.socketPath())
+// watchDeviceHealth checks the device file whenever its directory changes and periodically,
+// to also catch a device which is still present but can no longer be opened. Watch errors,
+// e.g. an overflowing event queue, trigger a check as events may have been lost. Health
+// transitions are handed over to the health channel.
+func (nedp *NitroEnclavesDevicePlugin) watchDeviceHealth(stop <-chan interface{}) {
+	devicePath := nedp.pdef.devicePath()
 
+	var events chan fsnotify.Event
+	var errs chan error
+	watcher, err := fsnotify.NewWatcher()
 	if err != nil {
-		glog.Errorf("Error while creating socket: %v", nedp.pdef.socketPath())
-		return err
+		glog.Errorf("Error while creating device watcher, falling back to polling: %v", err)
+	} else {
+		defer watcher.Close()
+		if err = watcher.Add(filepath.Dir(devicePath)); err != nil {
+			glog.Errorf("Error while watching %s, falling back to polling: %v", filepath.Dir(devicePath), err)
+		} else {
+			events, errs = watcher.Events, watcher.Errors
+		}
 	}
 
-	nedp.server = grpc.NewServer([]grpc.ServerOption{}...)
-	pluginapi.RegisterDevicePluginServer(nedp.server, nedp)
-	go func() {
-		err := nedp.server.Serve(sock)
-		if err != nil {
-			if nedp.stop != nil {
-				glog.Errorf("Error while serving device plugin: %v", err)
-				close(nedp.stop)
+	ticker := time.NewTicker(deviceHealthCheckInterval)
+	defer ticker.Stop()
+
+	last := ""
+	for {
+		if health := checkDeviceHealth(devicePath); health != last {
+			last = health
+			select {
+			case nedp.health <- health:
+			case <-stop:
+				return
 			}
 		}
-	}()
-	err = nedp.waitForServerReady(devicePluginServerReadyTimeout)
-	if err != nil {
-		return err
+
+		select {
+		case event, ok := <-events:
+			if !ok {
+				events = nil
+				continue
+			}
+			if event.Name != devicePath {
+				continue
+			}
+		case err, ok := <-errs:
+			if !ok {
+				errs = nil
+				continue
+			}
+			glog.Errorf("Error while watching %s, checking the device: %v", filepath.Dir(devicePath), err)
+		case <-ticker.C:
+		case <-stop:
+			return
+		}
+	}
+}
+
+// handleHealthUpdates applies health transitions reported by the health checker.
+func (nedp *NitroEnclavesDevicePlugin) handleHealthUpdates(stop <-chan interface{}) {
+	for {
+		select {
+		case health := <-nedp.health:
+			nedp.setHealth(health)
+		case <-stop:
+			return
+		}
 	}
+}
 
-	if err = nedp.register(pluginapi.KubeletSocket, nedp.ResourceName()); err != nil {
-		glog.Errorf("Error while registering device plugin with kubelet! (Reason: %s)", err)
-		nedp.Stop()
-		return err
+// ContainerAllocate mounts the Nitro Enclaves device and the extra devices and mounts into the
+// container and tells it the vsock CIDs reserved for the allocated devices.
+func (nedp *NitroEnclavesDevicePlugin) ContainerAllocate(req *pluginapi.ContainerAllocateRequest) (*pluginapi.ContainerAllocateResponse, error) {
+	for _, id := range req.DevicesIDs {
+		glog.V(1).Info("Allocation request for device ID: ", id)
 	}
 
-	glog.V(0).Infof("Registered device plugin with Kubelet: %v", nedp.ResourceName())
+	envs, err := nedp.cids.envs(req.DevicesIDs)
+	if err != nil {
+		return nil, err
+	}
 
-	return nil
-}
+	response := &pluginapi.ContainerAllocateResponse{
+		Envs: envs,
+		Devices: []*pluginapi.DeviceSpec{
+			{
+				ContainerPath: containerDevicePath,
+				HostPath:      nedp.pdef.hostDevicePath(),
+				Permissions:   "rw",
+			},
+		},
+	}
 
-// Stop device plugin server
-func (nedp *NitroEnclavesDevicePlugin) Stop() {
-	if nedp.server != nil {
-		nedp.server.Stop()
-		nedp.releaseResources()
-		glog.V(0).Infof("Device plugin stopped. (Socket: %s)", nedp.pdef.socketPath())
+	nedp.mutex.Lock()
+	defer nedp.mutex.Unlock()
+	for _, device := range nedp.extraDevices {
+		response.Devices = append(response.Devices, &pluginapi.DeviceSpec{
+			ContainerPath: device.Container(),
+			HostPath:      device.HostPath,
+			Permissions:   device.Perms(),
+		})
 	}
+	for _, mount := range nedp.extraMounts {
+		response.Mounts = append(response.Mounts, &pluginapi.Mount{
+			ContainerPath: mount.Container(),
+			HostPath:      mount.HostPath,
+			ReadOnly:      mount.ReadOnly,
+		})
+	}
+
+	return response, nil
 }
 
 // NewNitroEnclavesDevicePlugin returns an initialized NitroEnclavesDevicePlugin
@@ -264,16 +338,24 @@ func NewNitroEnclavesDevicePlugin(config *config.PluginConfig) *NitroEnclavesDev
 	devs := []*pluginapi.Device{}
 	for i := 0; i < config.MaxEnclavesPerNode; i++ {
 		devs = append(devs, &pluginapi.Device{
-			ID:     generateDeviceID(deviceName),
+			ID:     generateDeviceID(i),
 			Health: pluginapi.Healthy,
 		})
 	}
 	glog.V(0).Infof("Enclave devices added: %v", config.MaxEnclavesPerNode)
 
-	return &NitroEnclavesDevicePlugin{
-		dev:    devs,
-		pdef:   &NEPluginDefinitions{},
-		stop:   make(chan interface{}),
-		health: make(chan *pluginapi.Device),
+	nedp := &NitroEnclavesDevicePlugin{
+		dev:          devs,
+		slots:        config.MaxEnclavesPerNode,
+		deviceHealth: pluginapi.Healthy,
+		pdef:         &NEPluginDefinitions{config: config},
+		cids:         cidRange{base: config.EnclaveCIDBase, perSlot: config.EnclaveCIDsPerSlot},
+		extraDevices: config.EnclaveExtraDevices,
+		extraMounts:  config.EnclaveExtraMounts,
+		health:       make(chan string),
 	}
+	nedp.Plugin = device_plugin_framework.NewPlugin(nedp, config.DevicePluginPath())
+	nedp.Update()
+
+	return nedp
 }
@@ -4,46 +4,37 @@ package nitro_enclaves_device_plugin
 
 import (
 	"k8s-ne-device-plugin/pkg/config"
+	"k8s-ne-device-plugin/pkg/fake_kubelet"
+	"os"
+	"path/filepath"
+	"syscall"
 	"testing"
-)
-
-// generateDeviceID should always generate different device
-// IDs after each call. The expected result needs to
-// be ≤given-device-name><nb-of-calls-made-to-the-func>
-// nitro_enclaves0 ... nitro_enclaves99
-func TestIncrementalDeviceIdGenerationSuccess(t *testing.T) {
-	deviceName := "dummy_device"
-	expected := "dummy_device_0"
-
-	id := generateDeviceID(deviceName)
-
-	if expected != id {
-		t.Fatalf("Expected %s but got invalid id: %s!", expected, id)
-		return
-	}
+	"time"
 
-	deviceIdCounter = 99
-	_ = generateDeviceID(deviceName)
-	deviceName = "nitro_enclaves"
-	expected = "nitro_enclaves_100"
-	id = generateDeviceID(deviceName)
+	"golang.org/x/net/context"
+	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+)
 
-	if expected != id {
-		t.Fatalf("Expected %s but got invalid id: %s!", expected, id)
-		return
+// Device IDs are derived from the slot only, so every plugin instance advertises the same IDs.
+func TestDeviceIDs(t *testing.T) {
+	for i := 0; i < 2; i++ {
+		p := NewNitroEnclavesDevicePlugin(&config.PluginConfig{MaxEnclavesPerNode: 2})
+		if p.dev[0].ID != "nitro_enclaves_0" || p.dev[1].ID != "nitro_enclaves_1" {
+			t.Fatalf("Expected nitro_enclaves_0 and nitro_enclaves_1 but got %s and %s!", p.dev[0].ID, p.dev[1].ID)
+		}
 	}
 }
 
-func TestValidateDeviceNameSuccess(t *testing.T) {
-	deviceIdCounter = 50
-	// per default limited to max 4 devices
-	p := NewNitroEnclavesDevicePlugin(&config.PluginConfig{})
-
-	expected := "nitro_enclaves_50"
+// Slots removed and added again by a reload keep their IDs.
+func TestDeviceIDsAfterReconfigure(t *testing.T) {
+	p := NewNitroEnclavesDevicePlugin(&config.PluginConfig{MaxEnclavesPerNode: 3})
+	p.Reconfigure(&config.PluginConfig{MaxEnclavesPerNode: 1})
+	p.Reconfigure(&config.PluginConfig{MaxEnclavesPerNode: 4})
 
-	if expected != p.dev[0].ID {
-		t.Fatalf("Expected %s but got invalid id: %s!", expected, p.dev[0].ID)
-		return
+	for i, d := range p.dev {
+		if expected := generateDeviceID(i); d.ID != expected {
+			t.Fatalf("Expected %s but got invalid id: %s!", expected, d.ID)
+		}
 	}
 }
 
@@ -65,3 +56,231 @@ func TestValidateDeviceCustomNumber(t *testing.T) {
 		return
 	}
 }
+
+type fakePluginDefinitions struct {
+	IPluginDefinitions
+	device string
+}
+
+func (f *fakePluginDefinitions) devicePath() string {
+	return f.device
+}
+
+func expectHealth(t *testing.T, updates chan []*pluginapi.Device, health string) {
+	t.Helper()
+	select {
+	case devs := <-updates:
+		for _, d := range devs {
+			if d.Health != health {
+				t.Fatalf("Expected device %s to be %s but got %s!", d.ID, health, d.Health)
+			}
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("Timed out waiting for a %s device list!", health)
+	}
+}
+
+func TestCheckDeviceHealth(t *testing.T) {
+	devicePath := filepath.Join(t.TempDir(), deviceName)
+
+	if health := checkDeviceHealth(devicePath); health != pluginapi.Unhealthy {
+		t.Fatalf("Expected missing device to be %s but got %s!", pluginapi.Unhealthy, health)
+	}
+
+	if err := os.WriteFile(devicePath, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if health := checkDeviceHealth(devicePath); health != pluginapi.Healthy {
+		t.Fatalf("Expected present device to be %s but got %s!", pluginapi.Healthy, health)
+	}
+
+	if err := os.Chmod(devicePath, 0); err != nil {
+		t.Fatal(err)
+	}
+	if os.Geteuid() != 0 {
+		if health := checkDeviceHealth(devicePath); health != pluginapi.Unhealthy {
+			t.Fatalf("Expected unreadable device to be %s but got %s!", pluginapi.Unhealthy, health)
+		}
+	}
+}
+
+// Without access to the device in its device cgroup, the plugin container can't open the device,
+// which is then healthy as long as a character device is present.
+func TestCheckDeviceHealthPermissionDenied(t *testing.T) {
+	defer func(f func(string, int, os.FileMode) (*os.File, error)) { openFile = f }(openFile)
+	openFile = func(name string, _ int, _ os.FileMode) (*os.File, error) {
+		return nil, &os.PathError{Op: "open", Path: name, Err: syscall.EPERM}
+	}
+
+	if health := checkDeviceHealth("/dev/null"); health != pluginapi.Healthy {
+		t.Fatalf("Expected character device to be %s but got %s!", pluginapi.Healthy, health)
+	}
+
+	devicePath := filepath.Join(t.TempDir(), deviceName)
+	if health := checkDeviceHealth(devicePath); health != pluginapi.Unhealthy {
+		t.Fatalf("Expected missing device to be %s but got %s!", pluginapi.Unhealthy, health)
+	}
+	if err := os.WriteFile(devicePath, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if health := checkDeviceHealth(devicePath); health != pluginapi.Unhealthy {
+		t.Fatalf("Expected regular file to be %s but got %s!", pluginapi.Unhealthy, health)
+	}
+}
+
+// Every health transition must be pushed to all active ListAndWatch streams.
+func TestHealthChangesAreBroadcastToAllStreams(t *testing.T) {
+	p := NewNitroEnclavesDevicePlugin(&config.PluginConfig{MaxEnclavesPerNode: 2})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	streams := []*fake_kubelet.ListAndWatchServer{}
+	for i := 0; i < 2; i++ {
+		s := fake_kubelet.NewListAndWatchServer(ctx)
+		streams = append(streams, s)
+		go p.ListAndWatch(&pluginapi.Empty{}, s)
+		expectHealth(t, s.Updates, pluginapi.Healthy)
+	}
+
+	p.setHealth(pluginapi.Unhealthy)
+	for _, s := range streams {
+		expectHealth(t, s.Updates, pluginapi.Unhealthy)
+	}
+
+	p.setHealth(pluginapi.Healthy)
+	for _, s := range streams {
+		expectHealth(t, s.Updates, pluginapi.Healthy)
+	}
+}
+
+// The health checker must report the device as unhealthy when it disappears and as
+// healthy again when it comes back.
+func TestWatchDeviceHealthFollowsDevicePresence(t *testing.T) {
+	dir := t.TempDir()
+	devicePath := filepath.Join(dir, deviceName)
+	if err := os.WriteFile(devicePath, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	p := NewNitroEnclavesDevicePlugin(&config.PluginConfig{MaxEnclavesPerNode: 1})
+	p.pdef = &fakePluginDefinitions{device: devicePath}
+
+	stop := make(chan interface{})
+	defer close(stop)
+	go p.watchDeviceHealth(stop)
+
+	expectTransition := func(want string) {
+		t.Helper()
+		select {
+		case health := <-p.health:
+			if health != want {
+				t.Fatalf("Expected health %s but got %s!", want, health)
+			}
+		case <-time.After(2 * deviceHealthCheckInterval):
+			t.Fatalf("Timed out waiting for health %s!", want)
+		}
+	}
+
+	expectTransition(pluginapi.Healthy)
+
+	if err := os.Remove(devicePath); err != nil {
+		t.Fatal(err)
+	}
+	expectTransition(pluginapi.Unhealthy)
+
+	if err := os.WriteFile(devicePath, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	expectTransition(pluginapi.Healthy)
+}
+
+// Reconfigure should resize the slots in use and push the new list to active streams. Removed
+// slots are kept as unhealthy and become healthy again when added back.
+func TestReconfigureResizesDevices(t *testing.T) {
+	p := NewNitroEnclavesDevicePlugin(&config.PluginConfig{MaxEnclavesPerNode: 2})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	s := fake_kubelet.NewListAndWatchServer(ctx)
+	go p.ListAndWatch(&pluginapi.Empty{}, s)
+	expectHealth(t, s.Updates, pluginapi.Healthy)
+
+	for _, c := range []struct{ slots, devices, healthy int }{{4, 4, 4}, {1, 4, 1}, {3, 4, 3}} {
+		p.Reconfigure(&config.PluginConfig{MaxEnclavesPerNode: c.slots})
+		select {
+		case devs := <-s.Updates:
+			healthy := 0
+			for i, d := range devs {
+				if d.ID != generateDeviceID(i) {
+					t.Fatalf("Expected %s but got %s!", generateDeviceID(i), d.ID)
+				}
+				if d.Health == pluginapi.Healthy {
+					healthy++
+				}
+			}
+			if len(devs) != c.devices || healthy != c.healthy {
+				t.Fatalf("Expected %d devices, %d of them healthy, after reconfiguring to %d slots but got %d, %d healthy!", c.devices, c.healthy, c.slots, len(devs), healthy)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("Timed out waiting for the reconfigured device list!")
+		}
+	}
+
+	// removed slots stay unhealthy when the device becomes healthy
+	p.setHealth(pluginapi.Unhealthy)
+	expectHealth(t, s.Updates, pluginapi.Unhealthy)
+	p.setHealth(pluginapi.Healthy)
+	select {
+	case devs := <-s.Updates:
+		if devs[2].Health != pluginapi.Healthy || devs[3].Health != pluginapi.Unhealthy {
+			t.Fatalf("Expected only the slots in use to become healthy but got %v!", devs)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("Timed out waiting for the healthy device list!")
+	}
+}
+
+// The device file is read below DevRoot, but handed to the kubelet with its host path.
+func TestAllocateUsesHostDevicePath(t *testing.T) {
+	p := NewNitroEnclavesDevicePlugin(&config.PluginConfig{MaxEnclavesPerNode: 1, DevRoot: "/host/dev", HostDevRoot: "/custom/dev"})
+
+	if path := p.pdef.devicePath(); path != "/host/dev/nitro_enclaves" {
+		t.Errorf("devicePath() = %v, want /host/dev/nitro_enclaves", path)
+	}
+
+	resp, err := p.ContainerAllocate(&pluginapi.ContainerAllocateRequest{DevicesIDs: []string{p.dev[0].ID}})
+	if err != nil {
+		t.Fatalf("ContainerAllocate() error = %v", err)
+	}
+	spec := resp.Devices[0]
+	if spec.HostPath != "/custom/dev/nitro_enclaves" || spec.ContainerPath != "/dev/nitro_enclaves" {
+		t.Errorf("ContainerAllocate() device = %v, want /custom/dev/nitro_enclaves mounted to /dev/nitro_enclaves", spec)
+	}
+}
+
+// The extra devices and mounts are added to every allocation and follow config reloads.
+func TestAllocateExtras(t *testing.T) {
+	p := NewNitroEnclavesDevicePlugin(&config.PluginConfig{MaxEnclavesPerNode: 1})
+	p.Reconfigure(&config.PluginConfig{
+		MaxEnclavesPerNode:  1,
+		EnclaveExtraDevices: []config.DeviceSpec{{HostPath: "/dev/vsock"}, {HostPath: "/dev/foo", ContainerPath: "/dev/bar", Permissions: "r"}},
+		EnclaveExtraMounts:  []config.Mount{{HostPath: "/var/log/nitro_enclaves"}, {HostPath: "/run/nitro_enclaves", ReadOnly: true}},
+	})
+
+	resp, err := p.ContainerAllocate(&pluginapi.ContainerAllocateRequest{DevicesIDs: []string{p.dev[0].ID}})
+	if err != nil {
+		t.Fatalf("ContainerAllocate() error = %v", err)
+	}
+	if len(resp.Devices) != 3 || resp.Devices[0].ContainerPath != containerDevicePath {
+		t.Fatalf("ContainerAllocate() devices = %v, want the Nitro Enclaves device and 2 extra ones", resp.Devices)
+	}
+	if d := resp.Devices[1]; d.HostPath != "/dev/vsock" || d.ContainerPath != "/dev/vsock" || d.Permissions != "rw" {
+		t.Errorf("ContainerAllocate() device = %v, want /dev/vsock with rw", d)
+	}
+	if d := resp.Devices[2]; d.HostPath != "/dev/foo" || d.ContainerPath != "/dev/bar" || d.Permissions != "r" {
+		t.Errorf("ContainerAllocate() device = %v, want /dev/foo mounted to /dev/bar with r", d)
+	}
+	if len(resp.Mounts) != 2 || resp.Mounts[0].ContainerPath != "/var/log/nitro_enclaves" || resp.Mounts[0].ReadOnly || !resp.Mounts[1].ReadOnly {
+		t.Errorf("ContainerAllocate() mounts = %v, want a writable and a read-only mount", resp.Mounts)
+	}
+}
@@ -0,0 +1,51 @@
+// Copyright 2026 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package nitro_enclaves_device_plugin
+
+import (
+	"errors"
+	"io/fs"
+	"k8s-ne-device-plugin/pkg/config"
+	"k8s-ne-device-plugin/pkg/doctor"
+	"os"
+)
+
+// kernelModulePath is the sysfs directory of the loaded nitro_enclaves driver, relative to
+// the sysfs root.
+const kernelModulePath = "module/nitro_enclaves"
+
+// Diagnose checks the Nitro Enclaves device file and driver the plugin relies on.
+func Diagnose(config *config.PluginConfig) []doctor.Result {
+	results := []doctor.Result{}
+
+	modulePath := config.SysPath(kernelModulePath)
+	if _, err := os.Stat(modulePath); err != nil {
+		results = append(results, doctor.Failed("kernel module", "nitro_enclaves driver is not loaded: %v", err))
+	} else {
+		results = append(results, doctor.Passed("kernel module", "nitro_enclaves driver is loaded"))
+	}
+
+	devicePath := config.DevPath(deviceName)
+	info, err := os.Stat(devicePath)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		results = append(results, doctor.Failed("device", "%s does not exist", devicePath))
+	case err != nil:
+		results = append(results, doctor.Failed("device", "%s can't be accessed: %v", devicePath, err))
+	default:
+		err = openDevice(devicePath)
+		switch {
+		case errors.Is(err, fs.ErrPermission) && info.Mode()&os.ModeCharDevice != 0:
+			results = append(results, doctor.Passed("device", "%s (%v) is present, enclave containers get access through the allocation: %v", devicePath, info.Mode(), err))
+		case err != nil:
+			results = append(results, doctor.Failed("device", "%s (%v) can't be opened for reading and writing: %v", devicePath, info.Mode(), err))
+		case info.Mode()&os.ModeCharDevice == 0:
+			results = append(results, doctor.Warned("device", "%s (%v) is not a character device", devicePath, info.Mode()))
+		default:
+			results = append(results, doctor.Passed("device", "%s (%v) can be opened for reading and writing", devicePath, info.Mode()))
+		}
+	}
+
+	return results
+}
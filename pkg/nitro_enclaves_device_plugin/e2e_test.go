@@ -0,0 +1,120 @@
+// Copyright 2026 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+package nitro_enclaves_device_plugin_test
+
+import (
+	"errors"
+	"k8s-ne-device-plugin/pkg/config"
+	"k8s-ne-device-plugin/pkg/fake_kubelet"
+	"k8s-ne-device-plugin/pkg/nitro_enclaves_device_plugin"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+)
+
+const e2eTimeout = 10 * time.Second
+
+func allHealth(health string) func([]*pluginapi.Device) bool {
+	return func(devs []*pluginapi.Device) bool {
+		for _, d := range devs {
+			if d.Health != health {
+				return false
+			}
+		}
+		return len(devs) > 0
+	}
+}
+
+// The plugin registers with the kubelet, streams the health of the device file and mounts
+// the host device file into allocating containers.
+func TestEndToEnd(t *testing.T) {
+	devDir := t.TempDir()
+	device := filepath.Join(devDir, "nitro_enclaves")
+	if err := os.WriteFile(device, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	kubelet := fake_kubelet.New(t.TempDir())
+	if err := kubelet.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer kubelet.Stop()
+
+	p := nitro_enclaves_device_plugin.NewNitroEnclavesDevicePlugin(&config.PluginConfig{
+		MaxEnclavesPerNode: 2,
+		DevicePluginDir:    kubelet.Dir(),
+		DevRoot:            devDir,
+		HostDevRoot:        "/host/dev",
+		EnclaveCIDBase:     16,
+		EnclaveCIDsPerSlot: 2,
+	})
+	if err := p.Start(); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	defer p.Stop()
+
+	req, err := kubelet.WaitForRegistration("aws.ec2.nitro/nitro_enclaves", e2eTimeout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.Endpoint != "nitro_enclaves.sock" || req.Options.GetPreferredAllocationAvailable {
+		t.Errorf("Unexpected registration %v", req)
+	}
+
+	client, err := kubelet.Dial(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), e2eTimeout)
+	defer cancel()
+	stream, err := client.Watch(ctx)
+	if err != nil {
+		t.Fatalf("ListAndWatch() error = %v", err)
+	}
+	devs, err := stream.NextMatching(allHealth(pluginapi.Healthy), e2eTimeout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(devs) != 2 {
+		t.Fatalf("Expected 2 devices but got %v", devs)
+	}
+
+	if err = os.Remove(device); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = stream.NextMatching(allHealth(pluginapi.Unhealthy), e2eTimeout); err != nil {
+		t.Fatalf("Expected the devices to become unhealthy: %v", err)
+	}
+	if err = os.WriteFile(device, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = stream.NextMatching(allHealth(pluginapi.Healthy), e2eTimeout); err != nil {
+		t.Fatalf("Expected the devices to become healthy again: %v", err)
+	}
+
+	resp, err := client.Allocate(ctx, &pluginapi.AllocateRequest{
+		ContainerRequests: []*pluginapi.ContainerAllocateRequest{{DevicesIDs: []string{devs[1].ID}}},
+	})
+	if err != nil {
+		t.Fatalf("Allocate() error = %v", err)
+	}
+	spec := resp.ContainerResponses[0].Devices[0]
+	if spec.HostPath != "/host/dev/nitro_enclaves" || spec.ContainerPath != "/dev/nitro_enclaves" {
+		t.Errorf("Allocate() device = %v, want /host/dev/nitro_enclaves mounted to /dev/nitro_enclaves", spec)
+	}
+	if envs := resp.ContainerResponses[0].Envs; envs["NITRO_ENCLAVE_CID"] != "18" || envs["NITRO_ENCLAVE_CIDS"] != "18-19" {
+		t.Errorf("Allocate() envs = %v, want the CIDs 18-19 of the second slot", envs)
+	}
+
+	// Stopping the plugin ends the stream.
+	p.Stop()
+	if _, err = stream.NextMatching(func([]*pluginapi.Device) bool { return false }, e2eTimeout); !errors.Is(err, fake_kubelet.ErrStreamEnded) {
+		t.Error("Expected the ListAndWatch stream to end when the plugin stops")
+	}
+}
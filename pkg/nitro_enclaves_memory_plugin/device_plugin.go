@@ -0,0 +1,291 @@
+// Copyright 2026 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package nitro_enclaves_memory_plugin
+
+import (
+	"fmt"
+	"k8s-ne-device-plugin/pkg/allocator"
+	"k8s-ne-device-plugin/pkg/config"
+	"k8s-ne-device-plugin/pkg/device_plugin_framework"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+)
+
+const (
+	deviceName                = "nitro_enclaves_memory"
+	memoryPoolRefreshInterval = 10 * time.Second
+	memoryDeviceIDPrefix      = "memory_"
+
+	// Paths relative to the sysfs root, see config.PluginConfig.SysPath.
+	deviceHugepagesSysfsPath = "kernel/mm/hugepages"
+	deviceNodeSysfsPath      = "devices/system/node"
+
+	// noNUMANode identifies the node-less hugepage pool of /sys/kernel/mm/hugepages.
+	noNUMANode = -1
+
+	// Environment variable injected into containers allocating enclave memory.
+	enclaveMemoryEnv = "NITRO_ENCLAVES_MEMORY_MIB"
+)
+
+// NitroEnclavesMemoryDevicePlugin advertises the enclave hugepage pool as "aws.ec2.nitro/nitro_enclaves_memory".
+type NitroEnclavesMemoryDevicePlugin struct {
+	*device_plugin_framework.Plugin
+	*device_plugin_framework.DevicePool
+
+	blockSizeMiB       int
+	hugepagesSysfsPath string
+	nodeSysfsPath      string
+
+	allocatorConfigPath string
+
+	// mutex guards blockSizeMiB and allocatorConfigPath.
+	mutex sync.Mutex
+}
+
+// DeviceName returns the name of the resource within the aws.ec2.nitro namespace.
+func (nemdp *NitroEnclavesMemoryDevicePlugin) DeviceName() string {
+	return deviceName
+}
+
+// generateMemoryBlockID derives the device ID of a memory block from its NUMA node and its
+// index within the node's hugepage pool.
+func generateMemoryBlockID(node, block int) string {
+	if node == noNUMANode {
+		return memoryDeviceIDPrefix + strconv.Itoa(block)
+	}
+	return memoryDeviceIDPrefix + "node" + strconv.Itoa(node) + "_" + strconv.Itoa(block)
+}
+
+// readHugepagePool returns the amount of memory reserved as hugepages of any size in a
+// hugepages directory, e.g. /sys/kernel/mm/hugepages or /sys/devices/system/node/nodeX/hugepages.
+func readHugepagePool(dir string) (uint64, error) {
+	pools, err := filepath.Glob(filepath.Join(dir, "hugepages-*kB"))
+	if err != nil {
+		return 0, err
+	}
+	if len(pools) == 0 {
+		return 0, fmt.Errorf("no hugepage pools found in %s", dir)
+	}
+
+	var total uint64
+	for _, pool := range pools {
+		sizeKB, err := strconv.ParseUint(strings.TrimSuffix(strings.TrimPrefix(filepath.Base(pool), "hugepages-"), "kB"), 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid hugepage pool: %s", pool)
+		}
+		data, err := os.ReadFile(filepath.Join(pool, "nr_hugepages"))
+		if err != nil {
+			return 0, err
+		}
+		pages, err := strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid number of hugepages in %s: %w", pool, err)
+		}
+		total += pages * sizeKB * 1024
+	}
+
+	return total, nil
+}
+
+// readMemoryPool reads the hugepage memory per NUMA node and cross-checks it against the
+// allocator config. If sysfs can't be read, the memory_mib of the allocator config is used instead.
+func (nemdp *NitroEnclavesMemoryDevicePlugin) readMemoryPool() (map[int]uint64, error) {
+	nemdp.mutex.Lock()
+	allocatorConfigPath := nemdp.allocatorConfigPath
+	nemdp.mutex.Unlock()
+
+	allocatorConfig, allocatorErr := allocator.LoadConfig(allocatorConfigPath)
+	if allocatorErr != nil {
+		glog.V(1).Infof("Unable to read allocator config: %v", allocatorErr)
+	}
+
+	pool, err := nemdp.readHugepages()
+	if err == nil {
+		if allocatorConfig != nil {
+			var reserved uint64
+			for _, bytes := range pool {
+				reserved += bytes
+			}
+			nemdp.SetPoolMismatch(allocatorConfig.CheckMemory(reserved))
+		}
+		return pool, nil
+	}
+	glog.Errorf("Error reading enclave hugepage pool: %v", err)
+
+	if allocatorConfig == nil || allocatorConfig.MemoryMiB == 0 {
+		return nil, fmt.Errorf("no enclave memory pool available from sysfs (%v) or allocator config (%v)", err, allocatorErr)
+	}
+	glog.V(0).Infof("Falling back to the allocator memory_mib: %v", allocatorConfig.MemoryMiB)
+	return map[int]uint64{noNUMANode: uint64(allocatorConfig.MemoryMiB) * 1024 * 1024}, nil
+}
+
+// readHugepages returns the hugepage memory per NUMA node. If the per node hugepage pools
+// are not available, the whole pool is reported as noNUMANode.
+func (nemdp *NitroEnclavesMemoryDevicePlugin) readHugepages() (map[int]uint64, error) {
+	pool := map[int]uint64{}
+
+	nodes, _ := filepath.Glob(filepath.Join(nemdp.nodeSysfsPath, "node*"))
+	for _, dir := range nodes {
+		node, err := strconv.Atoi(strings.TrimPrefix(filepath.Base(dir), "node"))
+		if err != nil {
+			continue
+		}
+		bytes, err := readHugepagePool(filepath.Join(dir, "hugepages"))
+		if err != nil {
+			glog.V(1).Infof("Unable to read hugepages of NUMA node %d: %v", node, err)
+			continue
+		}
+		pool[node] = bytes
+	}
+	if len(pool) > 0 {
+		return pool, nil
+	}
+
+	bytes, err := readHugepagePool(nemdp.hugepagesSysfsPath)
+	if err != nil {
+		return nil, err
+	}
+	pool[noNUMANode] = bytes
+	return pool, nil
+}
+
+// updatePool rebuilds the device list from the hugepage memory per NUMA node, see
+// device_plugin_framework.DevicePool. Returns whether the advertised device list changed.
+func (nemdp *NitroEnclavesMemoryDevicePlugin) updatePool(pool map[int]uint64) bool {
+	nemdp.mutex.Lock()
+	blockSizeMiB := nemdp.blockSizeMiB
+	nemdp.mutex.Unlock()
+
+	if blockSizeMiB <= 0 {
+		return false
+	}
+
+	nodes := make([]int, 0, len(pool))
+	for node := range pool {
+		nodes = append(nodes, node)
+	}
+	sort.Ints(nodes)
+
+	blockSize := uint64(blockSizeMiB) * 1024 * 1024
+	devs := []*pluginapi.Device{}
+	for _, node := range nodes {
+		var topology *pluginapi.TopologyInfo
+		if node != noNUMANode {
+			topology = &pluginapi.TopologyInfo{Nodes: []*pluginapi.NUMANode{{ID: int64(node)}}}
+		}
+		for block := 0; block < int(pool[node]/blockSize); block++ {
+			devs = append(devs, &pluginapi.Device{ID: generateMemoryBlockID(node, block), Topology: topology})
+		}
+	}
+
+	return nemdp.UpdatePool(devs)
+}
+
+// refresh re-reads the hugepage pool and notifies all active ListAndWatch streams if the
+// advertised device list changed. A pool that cannot be read keeps the current device list.
+func (nemdp *NitroEnclavesMemoryDevicePlugin) refresh() {
+	pool, err := nemdp.readMemoryPool()
+	if err != nil {
+		glog.Errorf("Error while determining the enclave memory pool: %v", err)
+		return
+	}
+
+	if nemdp.updatePool(pool) {
+		glog.V(0).Infof("Enclave hugepage pool changed, advertising memory per NUMA node (bytes): %v", pool)
+		nemdp.Update()
+	}
+}
+
+// Reconfigure applies a reloaded plugin config and re-reads the hugepage pool, notifying all
+// active ListAndWatch streams if the advertised device list changed. A new block size
+// re-partitions the pool, blocks beyond the new partition are kept as unhealthy, see
+// device_plugin_framework.DevicePool.
+func (nemdp *NitroEnclavesMemoryDevicePlugin) Reconfigure(config *config.PluginConfig) {
+	nemdp.mutex.Lock()
+	nemdp.allocatorConfigPath = config.AllocatorConfigPath
+	if config.EnclaveMemoryBlockSizeMiB != nemdp.blockSizeMiB {
+		glog.V(0).Infof("Enclave memory block size changed from %v to %v MiB", nemdp.blockSizeMiB, config.EnclaveMemoryBlockSizeMiB)
+		nemdp.blockSizeMiB = config.EnclaveMemoryBlockSizeMiB
+	}
+	nemdp.mutex.Unlock()
+
+	nemdp.refresh()
+}
+
+// Watch periodically refreshes the hugepage pool until stop is closed.
+func (nemdp *NitroEnclavesMemoryDevicePlugin) Watch(stop <-chan interface{}) {
+	ticker := time.NewTicker(memoryPoolRefreshInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-stop:
+			return
+		}
+		nemdp.refresh()
+	}
+}
+
+// ContainerAllocate injects the amount of allocated enclave memory into the container. IDs of
+// blocks which were never advertised are rejected.
+func (nemdp *NitroEnclavesMemoryDevicePlugin) ContainerAllocate(req *pluginapi.ContainerAllocateRequest) (*pluginapi.ContainerAllocateResponse, error) {
+	for _, id := range req.DevicesIDs {
+		if !nemdp.HasDevice(id) {
+			return nil, fmt.Errorf("unknown enclave memory block device ID: %s", id)
+		}
+	}
+
+	nemdp.mutex.Lock()
+	blockSizeMiB := nemdp.blockSizeMiB
+	nemdp.mutex.Unlock()
+
+	glog.V(1).Infof("Allocation request for enclave memory blocks: %v", req.DevicesIDs)
+	return &pluginapi.ContainerAllocateResponse{
+		Envs: map[string]string{
+			enclaveMemoryEnv: strconv.Itoa(len(req.DevicesIDs) * blockSizeMiB),
+		},
+	}, nil
+}
+
+// newMemoryPoolReader returns a plugin which reads the enclave hugepage pool, but can't serve it yet.
+func newMemoryPoolReader(config *config.PluginConfig) *NitroEnclavesMemoryDevicePlugin {
+	return &NitroEnclavesMemoryDevicePlugin{
+		DevicePool:          device_plugin_framework.NewDevicePool(deviceName, "enclave hugepage pool"),
+		blockSizeMiB:        config.EnclaveMemoryBlockSizeMiB,
+		hugepagesSysfsPath:  config.SysPath(deviceHugepagesSysfsPath),
+		nodeSysfsPath:       config.SysPath(deviceNodeSysfsPath),
+		allocatorConfigPath: config.AllocatorConfigPath,
+	}
+}
+
+// NewNitroEnclavesMemoryDevicePlugin returns an initialized NitroEnclavesMemoryDevicePlugin
+func NewNitroEnclavesMemoryDevicePlugin(config *config.PluginConfig) *NitroEnclavesMemoryDevicePlugin {
+
+	if err := config.Validate(); err != nil {
+		glog.Errorf("invalid memory plugin config: %v", err)
+	}
+
+	glog.V(0).Infof("Initializing Nitro Enclaves memory device plugin with following params: %v", config)
+
+	nemdp := newMemoryPoolReader(config)
+	nemdp.Plugin = device_plugin_framework.NewPlugin(nemdp, config.DevicePluginPath())
+
+	// create a virtual device for each block of hugepage memory reserved by the AWS Nitro Enclave
+	// allocation service. The pool is re-read at runtime, see Watch.
+	if config.EnclaveMemoryAdvertisement {
+		nemdp.refresh()
+		glog.V(0).Infof("Enclave memory blocks of %v MiB added: %v", nemdp.blockSizeMiB, len(nemdp.Devices()))
+	}
+
+	return nemdp
+}
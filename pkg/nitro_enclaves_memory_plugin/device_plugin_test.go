@@ -0,0 +1,180 @@
+// Copyright 2026 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package nitro_enclaves_memory_plugin
+
+import (
+	"k8s-ne-device-plugin/pkg/config"
+	"k8s-ne-device-plugin/pkg/fake_kubelet"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+)
+
+func writeHugepages(t *testing.T, dir string, pages map[string]string) {
+	t.Helper()
+	for pool, nr := range pages {
+		if err := os.MkdirAll(filepath.Join(dir, pool), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, pool, "nr_hugepages"), []byte(nr+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func newTestPlugin(t *testing.T) *NitroEnclavesMemoryDevicePlugin {
+	t.Helper()
+	p := NewNitroEnclavesMemoryDevicePlugin(&config.PluginConfig{MaxEnclavesPerNode: 4, EnclaveMemoryBlockSizeMiB: 256})
+	p.hugepagesSysfsPath = t.TempDir()
+	p.nodeSysfsPath = t.TempDir()
+	return p
+}
+
+func TestGenerateMemoryBlockID(t *testing.T) {
+	if id := generateMemoryBlockID(1, 3); id != "memory_node1_3" {
+		t.Errorf("Expected memory_node1_3 but got %s", id)
+	}
+	if id := generateMemoryBlockID(noNUMANode, 3); id != "memory_3" {
+		t.Errorf("Expected memory_3 but got %s", id)
+	}
+}
+
+// Hugepages of all sizes count towards the pool of their NUMA node.
+func TestRefreshPerNUMANode(t *testing.T) {
+	p := newTestPlugin(t)
+	writeHugepages(t, filepath.Join(p.nodeSysfsPath, "node0", "hugepages"), map[string]string{
+		"hugepages-1048576kB": "1",
+		"hugepages-2048kB":    "256",
+	})
+	writeHugepages(t, filepath.Join(p.nodeSysfsPath, "node1", "hugepages"), map[string]string{
+		"hugepages-1048576kB": "0",
+		"hugepages-2048kB":    "128",
+	})
+	p.refresh()
+
+	perNode := map[int64]int{}
+	for _, d := range p.Devices() {
+		perNode[d.Topology.Nodes[0].ID]++
+	}
+	// node0: 1GiB + 512MiB = 6 blocks, node1: 256MiB = 1 block
+	if perNode[0] != 6 || perNode[1] != 1 {
+		t.Fatalf("Expected 6 blocks on node0 and 1 block on node1 but got %v", perNode)
+	}
+}
+
+// Without per NUMA node pools, the global pool is advertised without topology, and blocks
+// leaving the pool are marked unhealthy.
+func TestRefreshGlobalPool(t *testing.T) {
+	p := newTestPlugin(t)
+	writeHugepages(t, p.hugepagesSysfsPath, map[string]string{"hugepages-2048kB": "512"})
+	p.refresh()
+
+	devs := p.Devices()
+	if len(devs) != 4 || devs[0].Topology != nil {
+		t.Fatalf("Expected 4 blocks without topology but got %v", devs)
+	}
+
+	writeHugepages(t, p.hugepagesSysfsPath, map[string]string{"hugepages-2048kB": "256"})
+	p.refresh()
+
+	unhealthy := 0
+	for _, d := range p.Devices() {
+		if d.Health == pluginapi.Unhealthy {
+			unhealthy++
+		}
+	}
+	if unhealthy != 2 {
+		t.Fatalf("Expected 2 unhealthy blocks but got %d", unhealthy)
+	}
+}
+
+// A new block size re-partitions the pool, keeping the blocks beyond it as unhealthy, and is
+// broadcast once.
+func TestReconfigureBlockSize(t *testing.T) {
+	p := newTestPlugin(t)
+	writeHugepages(t, p.hugepagesSysfsPath, map[string]string{"hugepages-2048kB": "512"})
+	p.refresh()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stream := fake_kubelet.NewListAndWatchServer(ctx)
+	go p.ListAndWatch(&pluginapi.Empty{}, stream)
+	<-stream.Updates
+
+	p.Reconfigure(&config.PluginConfig{EnclaveMemoryBlockSizeMiB: 512})
+	select {
+	case devs := <-stream.Updates:
+		health := map[string]string{}
+		for _, d := range devs {
+			health[d.ID] = d.Health
+		}
+		want := map[string]string{"memory_0": pluginapi.Healthy, "memory_1": pluginapi.Healthy, "memory_2": pluginapi.Unhealthy, "memory_3": pluginapi.Unhealthy}
+		if !reflect.DeepEqual(health, want) {
+			t.Errorf("Devices after reconfiguration = %v, want %v", health, want)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Timed out waiting for the re-partitioned device list")
+	}
+	select {
+	case devs := <-stream.Updates:
+		t.Errorf("Expected a single update but got another one: %v", devs)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	resp, err := p.ContainerAllocate(&pluginapi.ContainerAllocateRequest{DevicesIDs: []string{"memory_0"}})
+	if err != nil || resp.Envs[enclaveMemoryEnv] != "512" {
+		t.Errorf("ContainerAllocate() = %v, %v, want %s=512", resp, err, enclaveMemoryEnv)
+	}
+}
+
+func TestAllocateInjectsMemorySize(t *testing.T) {
+	p := newTestPlugin(t)
+	writeHugepages(t, filepath.Join(p.nodeSysfsPath, "node0", "hugepages"), map[string]string{"hugepages-2048kB": "512"})
+	p.refresh()
+
+	resp, err := p.Allocate(context.Background(), &pluginapi.AllocateRequest{
+		ContainerRequests: []*pluginapi.ContainerAllocateRequest{
+			{DevicesIDs: []string{"memory_node0_0", "memory_node0_1", "memory_node0_2"}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Allocate() error = %v", err)
+	}
+	if got := resp.ContainerResponses[0].Envs[enclaveMemoryEnv]; got != "768" {
+		t.Errorf("Expected %s=768 but got %s", enclaveMemoryEnv, got)
+	}
+
+	for _, id := range []string{"memory_node0_99", "memory_node7_0", "cpu_1"} {
+		if _, err = p.ContainerAllocate(&pluginapi.ContainerAllocateRequest{DevicesIDs: []string{"memory_node0_0", id}}); err == nil {
+			t.Errorf("Expected unknown block %s to be rejected", id)
+		}
+	}
+}
+
+// The allocator config is cross-checked against sysfs and used as fallback if sysfs can't be read.
+func TestAllocatorConfigCrossCheckAndFallback(t *testing.T) {
+	p := newTestPlugin(t)
+	p.allocatorConfigPath = filepath.Join(t.TempDir(), "allocator.yaml")
+	if err := os.WriteFile(p.allocatorConfigPath, []byte("memory_mib: 2048\ncpu_count: 2\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	writeHugepages(t, p.hugepagesSysfsPath, map[string]string{"hugepages-2048kB": "512"})
+	p.refresh()
+	if p.PoolMismatch() == nil {
+		t.Fatal("Expected a mismatch between 2048 MiB allocator memory and 1024 MiB of hugepages")
+	}
+
+	fallback := newTestPlugin(t)
+	fallback.allocatorConfigPath = p.allocatorConfigPath
+	fallback.refresh()
+	if n := len(fallback.Devices()); n != 8 {
+		t.Fatalf("Expected 8 blocks derived from the allocator memory_mib but got %d", n)
+	}
+}
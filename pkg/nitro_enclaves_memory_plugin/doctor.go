@@ -0,0 +1,54 @@
+// Copyright 2026 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package nitro_enclaves_memory_plugin
+
+import (
+	"fmt"
+	"k8s-ne-device-plugin/pkg/config"
+	"k8s-ne-device-plugin/pkg/doctor"
+	"sort"
+	"strings"
+)
+
+// Diagnose checks the hugepage reservations the plugin advertises as enclave memory.
+func Diagnose(config *config.PluginConfig) []doctor.Result {
+	nemdp := newMemoryPoolReader(config)
+
+	pool, err := nemdp.readHugepages()
+	if err != nil {
+		return []doctor.Result{doctor.Failed("hugepages", "reserved hugepages can't be read: %v", err)}
+	}
+
+	nodes := make([]int, 0, len(pool))
+	var total uint64
+	for node, bytes := range pool {
+		nodes = append(nodes, node)
+		total += bytes
+	}
+	sort.Ints(nodes)
+	perNode := make([]string, 0, len(nodes))
+	for _, node := range nodes {
+		if node == noNUMANode {
+			perNode = append(perNode, fmt.Sprintf("%d MiB", pool[node]/1024/1024))
+		} else {
+			perNode = append(perNode, fmt.Sprintf("%d MiB on node %d", pool[node]/1024/1024, node))
+		}
+	}
+	reserved := strings.Join(perNode, ", ")
+
+	// readMemoryPool repeats the read above, but also cross-checks the allocator config
+	if _, err = nemdp.readMemoryPool(); err != nil {
+		return []doctor.Result{doctor.Failed("hugepages", "%v", err)}
+	}
+	switch {
+	case total == 0:
+		return []doctor.Result{doctor.Failed("hugepages", "no hugepages are reserved for enclaves")}
+	case nemdp.PoolMismatch() != nil:
+		return []doctor.Result{doctor.Warned("hugepages", "%s reserved, but %v", reserved, nemdp.PoolMismatch())}
+	case config.EnclaveMemoryAdvertisement && total < uint64(config.EnclaveMemoryBlockSizeMiB)*1024*1024:
+		return []doctor.Result{doctor.Warned("hugepages", "%s reserved, less than a single %d MiB block", reserved, config.EnclaveMemoryBlockSizeMiB)}
+	default:
+		return []doctor.Result{doctor.Passed("hugepages", "%s reserved", reserved)}
+	}
+}
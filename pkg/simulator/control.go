@@ -0,0 +1,113 @@
+// Copyright 2026 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package simulator
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"k8s-ne-device-plugin/pkg/cpulist"
+	"k8s-ne-device-plugin/pkg/http_endpoint"
+	"net/http"
+	"strconv"
+
+	"github.com/golang/glog"
+)
+
+// DefaultControlAddress is the default address of the control endpoint.
+const DefaultControlAddress = "127.0.0.1:8082"
+
+// errorHandler is an HTTP handler failing with a client error on errBadRequest and
+// ErrInvalidCPUs and with a server error otherwise.
+type errorHandler func(w http.ResponseWriter, r *http.Request) error
+
+var errBadRequest = errors.New("bad request")
+
+func (f errorHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if err := f(w, r); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, errBadRequest) || errors.Is(err, ErrInvalidCPUs) {
+			status = http.StatusBadRequest
+		}
+		http.Error(w, err.Error(), status)
+	}
+}
+
+// cpusParam parses the cpus query parameter in cpulist format.
+func cpusParam(r *http.Request) (cpulist.CPUSet, error) {
+	cpus, err := cpulist.Parse(r.URL.Query().Get("cpus"))
+	if err != nil || cpus.IsEmpty() {
+		return cpulist.CPUSet{}, fmt.Errorf("%w: cpus must be a non-empty cpulist, e.g. 2-3,6", errBadRequest)
+	}
+	return cpus, nil
+}
+
+// ControlHandler returns the handler injecting failures into h:
+//
+//	GET  /status                     the current state of the host
+//	POST /device/remove              remove the nitro_enclaves device file
+//	POST /device/restore             re-create the nitro_enclaves device file
+//	POST /cpus/online?cpus=<list>    bring CPUs online, e.g. to take them from the enclave pool
+//	POST /cpus/offline?cpus=<list>   take CPUs offline
+//	POST /hugepages?mib=<size>       resize the hugepage pool
+//
+// Every change but /status responds with the new state of the host.
+func ControlHandler(h *Host) http.Handler {
+	status := func(w http.ResponseWriter, r *http.Request) error {
+		w.Header().Set("Content-Type", "application/json")
+		return json.NewEncoder(w).Encode(h.Status())
+	}
+	change := func(apply func(r *http.Request) error) errorHandler {
+		return func(w http.ResponseWriter, r *http.Request) error {
+			if err := apply(r); err != nil {
+				return err
+			}
+			glog.V(0).Infof("Simulated host changed by %s: %+v", r.URL, h.Status())
+			return status(w, r)
+		}
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("GET /status", errorHandler(status))
+	mux.Handle("POST /device/remove", change(func(*http.Request) error {
+		return h.SetDevicePresent(false)
+	}))
+	mux.Handle("POST /device/restore", change(func(*http.Request) error {
+		return h.SetDevicePresent(true)
+	}))
+	mux.Handle("POST /cpus/online", change(func(r *http.Request) error {
+		cpus, err := cpusParam(r)
+		if err != nil {
+			return err
+		}
+		return h.SetCPUsOnline(cpus, true)
+	}))
+	mux.Handle("POST /cpus/offline", change(func(r *http.Request) error {
+		cpus, err := cpusParam(r)
+		if err != nil {
+			return err
+		}
+		return h.SetCPUsOnline(cpus, false)
+	}))
+	mux.Handle("POST /hugepages", change(func(r *http.Request) error {
+		mib, err := strconv.Atoi(r.URL.Query().Get("mib"))
+		if err != nil || mib < 0 {
+			return fmt.Errorf("%w: mib must be a non-negative number", errBadRequest)
+		}
+		return h.SetHugepagesMiB(mib)
+	}))
+	return mux
+}
+
+// ServeControl exposes the control endpoint of h on address in the background, see
+// http_endpoint.Serve.
+func ServeControl(address string, h *Host) (*http.Server, error) {
+	server, err := http_endpoint.Serve("Simulation control", address, ControlHandler(h))
+	if err != nil {
+		return nil, err
+	}
+	glog.V(0).Infof("Serving the simulation control endpoint on http://%s", server.Addr)
+
+	return server, nil
+}
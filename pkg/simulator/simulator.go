@@ -0,0 +1,441 @@
+// Copyright 2026 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package simulator builds a synthetic Nitro Enclaves host, i.e. the /dev/nitro_enclaves
+// device file, the CPU, NUMA and hugepage sysfs files and the allocator config, below a
+// root directory. The device plugins run against it unchanged via the /dev, /sys and
+// allocator config paths of the plugin config.
+package simulator
+
+import (
+	"errors"
+	"fmt"
+	"k8s-ne-device-plugin/pkg/config"
+	"k8s-ne-device-plugin/pkg/cpulist"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	deviceName = "nitro_enclaves"
+
+	// Paths relative to the root of the simulated host.
+	devPath             = "dev"
+	sysPath             = "sys"
+	cpuSysfsPath        = "sys/devices/system/cpu"
+	nodeSysfsPath       = "sys/devices/system/node"
+	hugepagesSysfsPath  = "sys/kernel/mm/hugepages"
+	neCPUsParamPath     = "sys/module/nitro_enclaves/parameters/ne_cpus"
+	allocatorConfigPath = "etc/nitro_enclaves/allocator.yaml"
+)
+
+// ErrInvalidCPUs is returned when changing CPUs which don't exist or can't be changed.
+var ErrInvalidCPUs = errors.New("invalid CPUs")
+
+// Profile describes the simulated host.
+type Profile struct {
+	// HostCPUs is the number of CPUs kept online for the host, starting with CPU 0.
+	HostCPUs int `yaml:"hostCPUs" json:"hostCPUs"`
+	// EnclaveCPUs is the number of offline CPUs in the nitro_enclaves driver CPU pool.
+	EnclaveCPUs int `yaml:"enclaveCPUs" json:"enclaveCPUs"`
+	// ThreadsPerCore is the number of hyperthread siblings of each core.
+	ThreadsPerCore int `yaml:"threadsPerCore" json:"threadsPerCore"`
+	// NUMANodes is the number of NUMA nodes, each of them a package of its own. The cores
+	// are spread evenly over the nodes.
+	NUMANodes int `yaml:"numaNodes" json:"numaNodes"`
+	// HugepagesMiB is the enclave hugepage pool, spread evenly over the NUMA nodes.
+	HugepagesMiB int `yaml:"hugepagesMiB" json:"hugepagesMiB"`
+	// HugepageSizeKiB is the size of a single hugepage.
+	HugepageSizeKiB int `yaml:"hugepageSizeKiB" json:"hugepageSizeKiB"`
+	// DevicePresent tells whether the nitro_enclaves device file exists.
+	DevicePresent bool `yaml:"devicePresent" json:"devicePresent"`
+}
+
+// DefaultProfile returns a host resembling a small enclave-enabled instance: 8 CPUs on one
+// NUMA node with two threads per core, half of them reserved for enclaves, and 1 GiB of
+// 2 MiB hugepages.
+func DefaultProfile() *Profile {
+	return &Profile{
+		HostCPUs:        4,
+		EnclaveCPUs:     4,
+		ThreadsPerCore:  2,
+		NUMANodes:       1,
+		HugepagesMiB:    1024,
+		HugepageSizeKiB: 2048,
+		DevicePresent:   true,
+	}
+}
+
+// LoadProfile reads a YAML or JSON profile. Fields missing in the file keep the values of
+// DefaultProfile.
+func LoadProfile(path string) (*Profile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	profile := DefaultProfile()
+	if err = yaml.Unmarshal(data, profile); err != nil {
+		return nil, fmt.Errorf("error parsing simulation profile %s: %w", path, err)
+	}
+	if err = profile.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid simulation profile %s: %w", path, err)
+	}
+	return profile, nil
+}
+
+// Validate checks that the profile describes a host the nitro_enclaves driver accepts:
+// enclaves get whole cores and CPU 0 stays with the host.
+func (p *Profile) Validate() error {
+	var errs []error
+	if p.ThreadsPerCore <= 0 {
+		errs = append(errs, fmt.Errorf("threadsPerCore must be greater than 0: %d", p.ThreadsPerCore))
+	}
+	if p.NUMANodes <= 0 {
+		errs = append(errs, fmt.Errorf("numaNodes must be greater than 0: %d", p.NUMANodes))
+	}
+	if p.HostCPUs <= 0 {
+		errs = append(errs, fmt.Errorf("hostCPUs must be greater than 0, as CPU 0 can't be used by enclaves: %d", p.HostCPUs))
+	}
+	if p.EnclaveCPUs < 0 {
+		errs = append(errs, fmt.Errorf("enclaveCPUs must not be negative: %d", p.EnclaveCPUs))
+	}
+	if p.HugepagesMiB < 0 {
+		errs = append(errs, fmt.Errorf("hugepagesMiB must not be negative: %d", p.HugepagesMiB))
+	}
+	if p.HugepageSizeKiB <= 0 {
+		errs = append(errs, fmt.Errorf("hugepageSizeKiB must be greater than 0: %d", p.HugepageSizeKiB))
+	}
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+
+	if p.HostCPUs%p.ThreadsPerCore != 0 || p.EnclaveCPUs%p.ThreadsPerCore != 0 {
+		errs = append(errs, fmt.Errorf("hostCPUs and enclaveCPUs must be multiples of threadsPerCore (%d)", p.ThreadsPerCore))
+	}
+	if p.cores()%p.NUMANodes != 0 {
+		errs = append(errs, fmt.Errorf("the %d cores can't be spread evenly over %d NUMA nodes", p.cores(), p.NUMANodes))
+	}
+	if (p.HugepagesMiB*1024)%(p.HugepageSizeKiB*p.NUMANodes) != 0 {
+		errs = append(errs, fmt.Errorf("hugepagesMiB must be a multiple of %d hugepages of %d KiB", p.NUMANodes, p.HugepageSizeKiB))
+	}
+	return errors.Join(errs...)
+}
+
+// allCPUs returns all CPUs of the host.
+func (p *Profile) allCPUs() cpulist.CPUSet {
+	cpus := make([]int, 0, p.HostCPUs+p.EnclaveCPUs)
+	for cpu := 0; cpu < p.HostCPUs+p.EnclaveCPUs; cpu++ {
+		cpus = append(cpus, cpu)
+	}
+	return cpulist.New(cpus...)
+}
+
+// cores returns the number of physical cores.
+func (p *Profile) cores() int {
+	return (p.HostCPUs + p.EnclaveCPUs) / p.ThreadsPerCore
+}
+
+// coreCPUs returns the hyperthread siblings of a core. Like on EC2 instances, the first
+// threads of all cores are numbered first, so core c runs CPUs c, c+cores, c+2*cores, ...
+func (p *Profile) coreCPUs(core int) []int {
+	cpus := make([]int, 0, p.ThreadsPerCore)
+	for thread := 0; thread < p.ThreadsPerCore; thread++ {
+		cpus = append(cpus, core+thread*p.cores())
+	}
+	return cpus
+}
+
+// nodeOfCore returns the NUMA node, and thus the package, of a core.
+func (p *Profile) nodeOfCore(core int) int {
+	return core / (p.cores() / p.NUMANodes)
+}
+
+// enclaveCPUs returns the CPUs reserved for enclaves: whole cores taken from the last one
+// downwards, like the allocator service prefers a single NUMA node away from CPU 0.
+func (p *Profile) enclaveCPUs() cpulist.CPUSet {
+	var cpus []int
+	for core := p.cores() - 1; len(cpus) < p.EnclaveCPUs; core-- {
+		cpus = append(cpus, p.coreCPUs(core)...)
+	}
+	return cpulist.New(cpus...)
+}
+
+// Host is a simulated Nitro Enclaves host below a root directory.
+type Host struct {
+	root    string
+	profile Profile
+
+	// mutex guards the files of the host and the state below.
+	mutex         sync.Mutex
+	online        cpulist.CPUSet
+	hugepagesMiB  int
+	devicePresent bool
+}
+
+// NewHost writes the host described by profile below root, which is created if needed.
+func NewHost(root string, profile *Profile) (*Host, error) {
+	if err := profile.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid simulation profile: %w", err)
+	}
+
+	h := &Host{
+		root:          root,
+		profile:       *profile,
+		online:        profile.allCPUs().Difference(profile.enclaveCPUs()),
+		hugepagesMiB:  profile.HugepagesMiB,
+		devicePresent: profile.DevicePresent,
+	}
+	if err := h.writeTopology(); err != nil {
+		return nil, err
+	}
+	if err := h.writeAllocatorConfig(); err != nil {
+		return nil, err
+	}
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	for _, write := range []func() error{h.writeOnlineCPUs, h.writeHugepages, h.writeDevice} {
+		if err := write(); err != nil {
+			return nil, err
+		}
+	}
+	return h, nil
+}
+
+// path returns the absolute path of a file of the host.
+func (h *Host) path(elem ...string) string {
+	return filepath.Join(append([]string{h.root}, elem...)...)
+}
+
+// writeFile writes a file of the host, creating its directory if needed.
+func (h *Host) writeFile(content string, elem ...string) error {
+	path := h.path(elem...)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, []byte(content), 0644)
+}
+
+// writeTopology writes the static NUMA topology and the nitro_enclaves driver CPU pool. The
+// core topology depends on the online CPUs, see writeCoreTopology.
+func (h *Host) writeTopology() error {
+	p := &h.profile
+	nodeCPUs := make([][]int, p.NUMANodes)
+	for core := 0; core < p.cores(); core++ {
+		node := p.nodeOfCore(core)
+		for _, cpu := range p.coreCPUs(core) {
+			nodeCPUs[node] = append(nodeCPUs[node], cpu)
+			dir := "cpu" + strconv.Itoa(cpu)
+			link := h.path(cpuSysfsPath, dir, "node"+strconv.Itoa(node))
+			if err := os.MkdirAll(filepath.Dir(link), 0755); err != nil {
+				return err
+			}
+			os.Remove(link)
+			if err := os.Symlink(filepath.Join("..", "..", "node", "node"+strconv.Itoa(node)), link); err != nil {
+				return err
+			}
+		}
+	}
+
+	for node, cpus := range nodeCPUs {
+		if err := h.writeFile(cpulist.New(cpus...).String()+"\n", nodeSysfsPath, "node"+strconv.Itoa(node), "cpulist"); err != nil {
+			return err
+		}
+	}
+	for _, name := range []string{"possible", "present"} {
+		if err := h.writeFile(p.allCPUs().String()+"\n", cpuSysfsPath, name); err != nil {
+			return err
+		}
+	}
+	return h.writeFile(p.enclaveCPUs().String()+"\n", neCPUsParamPath)
+}
+
+// writeAllocatorConfig writes the allocator config matching the profile.
+func (h *Host) writeAllocatorConfig() error {
+	data, err := yaml.Marshal(map[string]interface{}{
+		"memory_mib": h.profile.HugepagesMiB,
+		"cpu_pool":   h.profile.enclaveCPUs().String(),
+	})
+	if err != nil {
+		return err
+	}
+	return h.writeFile(string(data), allocatorConfigPath)
+}
+
+// writeCoreTopology writes the core topology of the online CPUs. Like the kernel, it removes the
+// topology of offline CPUs and doesn't list them as siblings. Must be called with mutex held.
+func (h *Host) writeCoreTopology() error {
+	p := &h.profile
+	for core := 0; core < p.cores(); core++ {
+		siblings := cpulist.New(p.coreCPUs(core)...).Intersection(h.online).String()
+		for _, cpu := range p.coreCPUs(core) {
+			dir := "cpu" + strconv.Itoa(cpu)
+			if !h.online.Contains(cpu) {
+				if err := os.RemoveAll(h.path(cpuSysfsPath, dir, "topology")); err != nil {
+					return err
+				}
+				continue
+			}
+			files := map[string]string{
+				"core_id":              strconv.Itoa(core % (p.cores() / p.NUMANodes)),
+				"physical_package_id":  strconv.Itoa(p.nodeOfCore(core)),
+				"thread_siblings_list": siblings,
+				"core_cpus_list":       siblings,
+			}
+			for name, content := range files {
+				if err := h.writeFile(content+"\n", cpuSysfsPath, dir, "topology", name); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// writeOnlineCPUs writes the online and offline CPU lists and the core topology. Must be called
+// with mutex held.
+func (h *Host) writeOnlineCPUs() error {
+	all := h.profile.allCPUs()
+	for _, cpu := range all.List() {
+		// CPU 0 can't be hot unplugged and has no online file
+		if cpu == 0 {
+			continue
+		}
+		online := "0\n"
+		if h.online.Contains(cpu) {
+			online = "1\n"
+		}
+		if err := h.writeFile(online, cpuSysfsPath, "cpu"+strconv.Itoa(cpu), "online"); err != nil {
+			return err
+		}
+	}
+
+	if err := h.writeFile(h.online.String()+"\n", cpuSysfsPath, "online"); err != nil {
+		return err
+	}
+	if err := h.writeFile(all.Difference(h.online).String()+"\n", cpuSysfsPath, "offline"); err != nil {
+		return err
+	}
+	return h.writeCoreTopology()
+}
+
+// writeHugepages writes the hugepage pools of the NUMA nodes and their sum. Must be called
+// with mutex held.
+func (h *Host) writeHugepages() error {
+	pool := "hugepages-" + strconv.Itoa(h.profile.HugepageSizeKiB) + "kB"
+	pages := h.hugepagesMiB * 1024 / h.profile.HugepageSizeKiB
+	nodePages := pages / h.profile.NUMANodes
+	for node := 0; node < h.profile.NUMANodes; node++ {
+		if err := h.writeFile(strconv.Itoa(nodePages)+"\n", nodeSysfsPath, "node"+strconv.Itoa(node), "hugepages", pool, "nr_hugepages"); err != nil {
+			return err
+		}
+	}
+	return h.writeFile(strconv.Itoa(nodePages*h.profile.NUMANodes)+"\n", hugepagesSysfsPath, pool, "nr_hugepages")
+}
+
+// writeDevice creates or removes the nitro_enclaves device file. Must be called with mutex held.
+func (h *Host) writeDevice() error {
+	device := h.path(devPath, deviceName)
+	if !h.devicePresent {
+		if err := os.Remove(device); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(device), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(device, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// Root returns the root directory of the host.
+func (h *Host) Root() string {
+	return h.root
+}
+
+// Apply points the /dev, /sys and allocator config paths of c to the host. The device file
+// is handed to the kubelet with the same path, so the root must be shared with the node.
+func (h *Host) Apply(c *config.PluginConfig) {
+	c.DevRoot = h.path(devPath)
+	c.HostDevRoot = h.path(devPath)
+	c.SysRoot = h.path(sysPath)
+	c.AllocatorConfigPath = h.path(allocatorConfigPath)
+}
+
+// SetDevicePresent creates or removes the nitro_enclaves device file.
+func (h *Host) SetDevicePresent(present bool) error {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	h.devicePresent = present
+	return h.writeDevice()
+}
+
+// SetCPUsOnline brings cpus online or takes them offline. CPU 0 can't be taken offline.
+func (h *Host) SetCPUsOnline(cpus cpulist.CPUSet, online bool) error {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	for _, cpu := range cpus.List() {
+		if !h.profile.allCPUs().Contains(cpu) {
+			return fmt.Errorf("%w: CPU %d does not exist", ErrInvalidCPUs, cpu)
+		}
+		if cpu == 0 && !online {
+			return fmt.Errorf("%w: CPU 0 can't be taken offline", ErrInvalidCPUs)
+		}
+	}
+
+	if online {
+		h.online = h.online.Union(cpus)
+	} else {
+		h.online = h.online.Difference(cpus)
+	}
+	return h.writeOnlineCPUs()
+}
+
+// SetHugepagesMiB resizes the hugepage pool, spread evenly over the NUMA nodes.
+func (h *Host) SetHugepagesMiB(mib int) error {
+	if mib < 0 {
+		return fmt.Errorf("hugepage pool must not be negative: %d MiB", mib)
+	}
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	h.hugepagesMiB = mib
+	return h.writeHugepages()
+}
+
+// Status is the current state of a simulated host.
+type Status struct {
+	Profile       Profile `json:"profile"`
+	Root          string  `json:"root"`
+	DevicePresent bool    `json:"devicePresent"`
+	OnlineCPUs    string  `json:"onlineCPUs"`
+	EnclaveCPUs   string  `json:"enclaveCPUs"`
+	HugepagesMiB  int     `json:"hugepagesMiB"`
+}
+
+// Status returns the current state of the host.
+func (h *Host) Status() Status {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	return Status{
+		Profile:       h.profile,
+		Root:          h.root,
+		DevicePresent: h.devicePresent,
+		OnlineCPUs:    h.online.String(),
+		EnclaveCPUs:   h.profile.enclaveCPUs().String(),
+		HugepagesMiB:  h.hugepagesMiB,
+	}
+}
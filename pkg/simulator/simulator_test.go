@@ -0,0 +1,224 @@
+// Copyright 2026 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package simulator
+
+import (
+	"encoding/json"
+	"k8s-ne-device-plugin/pkg/allocator"
+	"k8s-ne-device-plugin/pkg/config"
+	"k8s-ne-device-plugin/pkg/nitro_enclaves_cpu_plugin"
+	"k8s-ne-device-plugin/pkg/nitro_enclaves_memory_plugin"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+)
+
+// twoNodeProfile has 4 cores per NUMA node with two threads each; the enclaves get the last
+// two cores of node 1.
+func twoNodeProfile() *Profile {
+	return &Profile{
+		HostCPUs:        12,
+		EnclaveCPUs:     4,
+		ThreadsPerCore:  2,
+		NUMANodes:       2,
+		HugepagesMiB:    1024,
+		HugepageSizeKiB: 2048,
+		DevicePresent:   true,
+	}
+}
+
+// healthByID maps the IDs of devs to their health.
+func healthByID(devs []*pluginapi.Device) map[string]string {
+	health := map[string]string{}
+	for _, d := range devs {
+		health[d.ID] = d.Health
+	}
+	return health
+}
+
+func TestLoadProfile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "profile.yaml")
+	if err := os.WriteFile(path, []byte("enclaveCPUs: 2\nnumaNodes: 1\ndevicePresent: false\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	profile, err := LoadProfile(path)
+	if err != nil {
+		t.Fatalf("LoadProfile() error = %v", err)
+	}
+	want := DefaultProfile()
+	want.EnclaveCPUs = 2
+	want.DevicePresent = false
+	if *profile != *want {
+		t.Errorf("LoadProfile() = %+v, want %+v", profile, want)
+	}
+
+	if err = os.WriteFile(path, []byte("hostCPUs: 3\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = LoadProfile(path); err == nil || !strings.Contains(err.Error(), "multiples of threadsPerCore") {
+		t.Errorf("LoadProfile() error = %v, want an error about split cores", err)
+	}
+}
+
+func TestProfileValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		profile func(p *Profile)
+	}{
+		{name: "no host CPUs", profile: func(p *Profile) { p.HostCPUs, p.EnclaveCPUs = 0, 8 }},
+		{name: "no threads", profile: func(p *Profile) { p.ThreadsPerCore = 0 }},
+		{name: "uneven NUMA nodes", profile: func(p *Profile) { p.NUMANodes = 3 }},
+		{name: "partial hugepages", profile: func(p *Profile) { p.HugepagesMiB = 1023 }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			profile := DefaultProfile()
+			tt.profile(profile)
+			if profile.Validate() == nil {
+				t.Errorf("Expected %+v to be invalid", profile)
+			}
+		})
+	}
+}
+
+// The plugins read the simulated host like a real one.
+func TestPluginsRunAgainstSimulatedHost(t *testing.T) {
+	host, err := NewHost(t.TempDir(), twoNodeProfile())
+	if err != nil {
+		t.Fatalf("NewHost() error = %v", err)
+	}
+	cfg := config.Defaults()
+	cfg.EnclaveCPUAdvertisement = true
+	cfg.EnclaveMemoryAdvertisement = true
+	host.Apply(cfg)
+
+	if _, err = os.Stat(cfg.DevPath("nitro_enclaves")); err != nil {
+		t.Errorf("Expected the device file to be present: %v", err)
+	}
+	allocatorConfig, err := allocator.LoadConfig(cfg.AllocatorConfigPath)
+	if err != nil || allocatorConfig.CPUPool != "6-7,14-15" || allocatorConfig.MemoryMiB != 1024 {
+		t.Errorf("Unexpected allocator config %+v, %v", allocatorConfig, err)
+	}
+
+	// like the kernel, only online CPUs have a core topology
+	if _, err = os.Stat(filepath.Join(host.Root(), cpuSysfsPath, "cpu6", "topology")); !os.IsNotExist(err) {
+		t.Errorf("Expected no topology of the offline CPU 6, got %v", err)
+	}
+	if siblings, err := os.ReadFile(filepath.Join(host.Root(), cpuSysfsPath, "cpu5", "topology", "thread_siblings_list")); err != nil || string(siblings) != "5,13\n" {
+		t.Errorf("Expected the siblings 5,13 of the online CPU 5, got %q, %v", siblings, err)
+	}
+
+	cpus := nitro_enclaves_cpu_plugin.NewNitroEnclavesCPUDevicePlugin(cfg)
+	devs := cpus.Devices()
+	if len(devs) != 4 {
+		t.Fatalf("Expected 4 enclave CPUs but got %v", devs)
+	}
+	for _, d := range devs {
+		if d.Health != pluginapi.Healthy || d.Topology == nil || d.Topology.Nodes[0].ID != 1 {
+			t.Errorf("Expected %v to be a healthy CPU of NUMA node 1", d)
+		}
+	}
+	if err = cpus.PoolMismatch(); err != nil {
+		t.Errorf("PoolMismatch() = %v", err)
+	}
+	preferred, err := cpus.PreferredAllocation(&pluginapi.ContainerPreferredAllocationRequest{
+		AvailableDeviceIDs: []string{"cpu_6", "cpu_7", "cpu_14", "cpu_15"},
+		AllocationSize:     2,
+	})
+	if err != nil || strings.Join(preferred, ",") != "cpu_6,cpu_14" {
+		t.Errorf("PreferredAllocation() = %v, %v, want the siblings cpu_6 and cpu_14", preferred, err)
+	}
+
+	memory := nitro_enclaves_memory_plugin.NewNitroEnclavesMemoryDevicePlugin(cfg)
+	if devs := memory.Devices(); len(devs) != 4 || devs[0].Topology.Nodes[0].ID != 0 || devs[3].Topology.Nodes[0].ID != 1 {
+		t.Errorf("Expected 2 blocks of 256 MiB per NUMA node but got %v", devs)
+	}
+	if err = memory.PoolMismatch(); err != nil {
+		t.Errorf("PoolMismatch() = %v", err)
+	}
+}
+
+// post sends a control request and decodes the returned state of the host.
+func post(t *testing.T, server *httptest.Server, path string, wantStatus int) Status {
+	t.Helper()
+	resp, err := http.Post(server.URL+path, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != wantStatus {
+		t.Fatalf("POST %s = %v, want %v", path, resp.StatusCode, wantStatus)
+	}
+
+	var status Status
+	if wantStatus == http.StatusOK {
+		if err = json.NewDecoder(resp.Body).Decode(&status); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return status
+}
+
+// Failures injected through the control endpoint reach the plugins on their next refresh.
+func TestControlInjectsFailures(t *testing.T) {
+	host, err := NewHost(t.TempDir(), twoNodeProfile())
+	if err != nil {
+		t.Fatalf("NewHost() error = %v", err)
+	}
+	cfg := config.Defaults()
+	cfg.EnclaveCPUAdvertisement = true
+	cfg.EnclaveMemoryAdvertisement = true
+	host.Apply(cfg)
+	// like the kernel, only online CPUs have a core topology
+	if _, err = os.Stat(filepath.Join(host.Root(), cpuSysfsPath, "cpu6", "topology")); !os.IsNotExist(err) {
+		t.Errorf("Expected no topology of the offline CPU 6, got %v", err)
+	}
+	if siblings, err := os.ReadFile(filepath.Join(host.Root(), cpuSysfsPath, "cpu5", "topology", "thread_siblings_list")); err != nil || string(siblings) != "5,13\n" {
+		t.Errorf("Expected the siblings 5,13 of the online CPU 5, got %q, %v", siblings, err)
+	}
+
+	cpus := nitro_enclaves_cpu_plugin.NewNitroEnclavesCPUDevicePlugin(cfg)
+	memory := nitro_enclaves_memory_plugin.NewNitroEnclavesMemoryDevicePlugin(cfg)
+
+	server := httptest.NewServer(ControlHandler(host))
+	defer server.Close()
+
+	if status := post(t, server, "/device/remove", http.StatusOK); status.DevicePresent {
+		t.Error("Expected the device to be reported missing")
+	}
+	if _, err = os.Stat(cfg.DevPath("nitro_enclaves")); !os.IsNotExist(err) {
+		t.Errorf("Expected the device file to be removed, got %v", err)
+	}
+	post(t, server, "/device/restore", http.StatusOK)
+	if _, err = os.Stat(cfg.DevPath("nitro_enclaves")); err != nil {
+		t.Errorf("Expected the device file to be restored: %v", err)
+	}
+
+	if status := post(t, server, "/cpus/online?cpus=7,15", http.StatusOK); status.OnlineCPUs != "0-5,7-13,15" {
+		t.Errorf("Online CPUs = %v, want 0-5,7-13,15", status.OnlineCPUs)
+	}
+	cpus.Reconfigure(cfg)
+	if health := healthByID(cpus.Devices()); health["cpu_7"] != pluginapi.Unhealthy || health["cpu_6"] != pluginapi.Healthy {
+		t.Errorf("Expected only cpu_7 and cpu_15 to become unhealthy but got %v", health)
+	}
+	if mismatch := cpus.PoolMismatch(); mismatch == nil || !strings.Contains(mismatch.Error(), "online") {
+		t.Errorf("Expected the online pool CPUs to be reported but got %v", mismatch)
+	}
+
+	post(t, server, "/hugepages?mib=512", http.StatusOK)
+	memory.Reconfigure(cfg)
+	if health := healthByID(memory.Devices()); health["memory_node0_0"] != pluginapi.Healthy || health["memory_node0_1"] != pluginapi.Unhealthy {
+		t.Errorf("Expected one block per NUMA node to remain but got %v", health)
+	}
+
+	post(t, server, "/cpus/offline?cpus=0", http.StatusBadRequest)
+	post(t, server, "/cpus/online?cpus=16", http.StatusBadRequest)
+	post(t, server, "/cpus/online", http.StatusBadRequest)
+	post(t, server, "/hugepages?mib=-1", http.StatusBadRequest)
+}